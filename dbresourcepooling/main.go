@@ -1,33 +1,86 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sync"
-	"test/pool"
+	"sync/atomic"
 	"time"
+
+	respool "github.com/Kama001/goadvanced/generics/resourcepoling/pool"
+	"test/pool"
+	"test/pool/fakedb"
 )
 
 func main() {
 	// Example 1: Database connection pool
 	fmt.Println("1. Database Connection Pool Example:")
-	dbPool := pool.New(3, 5*time.Second)
+	var nextID atomic.Int64
+	newConn := func(ctx context.Context) (*pool.DBConnection, error) {
+		return &pool.DBConnection{ID: int(nextID.Add(1)) - 1}, nil
+	}
+	dbPool, err := respool.New(newConn, 3, 3)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	defer dbPool.Close()
+	dbPool.SetAcquireTimeout(5 * time.Second)
 
 	var wg sync.WaitGroup
 	for i := 1; i <= 5; i++ {
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
-			conn := dbPool.Get()
-			if conn != nil {
-				defer dbPool.Put(conn)
-				fmt.Printf("Worker %d: Using connection %d\n", id, conn.ID)
-				time.Sleep(4 * time.Second)
+			conn, err := dbPool.Get(context.Background())
+			if err != nil {
+				fmt.Printf("Worker %d: %s\n", id, err)
+				return
 			}
+			defer dbPool.Put(conn)
+			fmt.Printf("Worker %d: Using connection %d\n", id, conn.ID)
+			time.Sleep(4 * time.Second)
 		}(i)
 	}
 	wg.Wait()
+	s := dbPool.Stats()
+	fmt.Printf("Pool stats: %d open, %d idle, %d gets waited %s\n",
+		s.OpenConnections, s.Idle, s.WaitCount, s.WaitDuration.Round(time.Millisecond))
 
+	// Example 2: Pool of database/sql sessions
+	fmt.Println("2. database/sql Session Pool Example:")
+	db := fakedb.New().OpenDB()
+	defer db.Close()
+	sqlPool, err := pool.NewWithFactory(2, 2, &pool.SQLFactory{DB: db})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer sqlPool.Close()
+	sqlPool.SetAcquireTimeout(5 * time.Second)
+	sqlPool.SetMaxLifetime(time.Minute)
+	sqlPool.SetSlowBorrowThreshold(100 * time.Millisecond)
+
+	ctx := respool.WithBorrower(context.Background(), "report")
+	policy := respool.RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, Classify: pool.Classify}
+	err = sqlPool.DoWithRetry(ctx, func(conn *pool.DBConnection) error {
+		if _, err := conn.Conn.ExecContext(ctx, "SET worker ?", conn.ID); err != nil {
+			return err
+		}
+		var value string
+		if err := conn.Conn.QueryRowContext(ctx, "GET worker").Scan(&value); err != nil {
+			return err
+		}
+		fmt.Printf("Connection %d read back %s\n", conn.ID, value)
+		time.Sleep(150 * time.Millisecond)
+		return nil
+	}, policy)
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, s := range sqlPool.Inspect() {
+		fmt.Printf("Connection %d: %d borrows, longest %s\n", s.ID, s.Borrows, s.LongestBorrow.Round(time.Millisecond))
+	}
 }
 
 // package main
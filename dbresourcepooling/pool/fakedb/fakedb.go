@@ -0,0 +1,246 @@
+// Package fakedb is an in-memory database/sql driver for exercising the
+// connection pool without a database server. It understands two
+// statements, "SET key value" and "GET key", where any word can be a ?
+// placeholder.
+package fakedb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Driver is both the driver and its connector, so each Driver is an
+// isolated database.
+type Driver struct {
+	mu        sync.Mutex
+	data      map[string]string
+	conns     map[*conn]bool
+	opened    int
+	openErr   error
+	openDelay time.Duration
+}
+
+func New() *Driver {
+	return &Driver{
+		data:  make(map[string]string),
+		conns: make(map[*conn]bool),
+	}
+}
+
+// OpenDB returns a *sql.DB backed by d.
+func (d *Driver) OpenDB() *sql.DB {
+	return sql.OpenDB(d)
+}
+
+func (d *Driver) Connect(ctx context.Context) (driver.Conn, error) {
+	d.mu.Lock()
+	delay := d.openDelay
+	d.mu.Unlock()
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return d.Open("")
+}
+
+func (d *Driver) Driver() driver.Driver {
+	return d
+}
+
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.openErr != nil {
+		return nil, d.openErr
+	}
+	c := &conn{d: d}
+	d.conns[c] = true
+	d.opened++
+	return c, nil
+}
+
+// SetOpenError makes new connections fail with err, or succeed again when
+// err is nil.
+func (d *Driver) SetOpenError(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.openErr = err
+}
+
+// SetOpenDelay makes new connections take d to open, as if the server
+// were slow to accept them. Connect gives up early if its ctx ends.
+func (d *Driver) SetOpenDelay(delay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.openDelay = delay
+}
+
+// BreakConns marks every open connection as dead, as if the server had
+// dropped them. Dead connections fail with driver.ErrBadConn.
+func (d *Driver) BreakConns() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for c := range d.conns {
+		c.dead = true
+	}
+}
+
+// OpenConns returns how many connections are open.
+func (d *Driver) OpenConns() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.conns)
+}
+
+// Opened returns how many connections have been opened in total.
+func (d *Driver) Opened() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.opened
+}
+
+type conn struct {
+	d    *Driver
+	dead bool // guarded by d.mu
+}
+
+func (c *conn) alive() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if c.dead {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	if err := c.alive(); err != nil {
+		return nil, err
+	}
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return nil, errors.New("fakedb: empty statement")
+	}
+	verb := strings.ToUpper(words[0])
+	switch {
+	case verb == "SET" && len(words) == 3, verb == "GET" && len(words) == 2:
+	default:
+		return nil, fmt.Errorf("fakedb: cannot parse %q", query)
+	}
+	return &stmt{c: c, verb: verb, words: words[1:]}, nil
+}
+
+func (c *conn) Close() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	delete(c.d.conns, c)
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fakedb: transactions are not supported")
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.alive()
+}
+
+type stmt struct {
+	c     *conn
+	verb  string
+	words []string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	n := 0
+	for _, w := range s.words {
+		if w == "?" {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *stmt) bind(args []driver.Value) []string {
+	bound := make([]string, len(s.words))
+	for i, w := range s.words {
+		if w == "?" {
+			w, args = fmt.Sprint(args[0]), args[1:]
+		}
+		bound[i] = w
+	}
+	return bound
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.c.alive(); err != nil {
+		return nil, err
+	}
+	if s.verb != "SET" {
+		return nil, fmt.Errorf("fakedb: %s is a query", s.verb)
+	}
+	words := s.bind(args)
+	s.c.d.mu.Lock()
+	s.c.d.data[words[0]] = words[1]
+	s.c.d.mu.Unlock()
+	return driver.RowsAffected(1), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.c.alive(); err != nil {
+		return nil, err
+	}
+	if s.verb != "GET" {
+		return nil, fmt.Errorf("fakedb: %s is not a query", s.verb)
+	}
+	words := s.bind(args)
+	s.c.d.mu.Lock()
+	value, ok := s.c.d.data[words[0]]
+	s.c.d.mu.Unlock()
+	r := &rows{}
+	if ok {
+		r.values = []string{value}
+	}
+	return r, nil
+}
+
+type rows struct {
+	values []string
+}
+
+func (r *rows) Columns() []string {
+	return []string{"value"}
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
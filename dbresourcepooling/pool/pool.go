@@ -1,173 +1,95 @@
-// package pool
-
-// import (
-// 	"fmt"
-// 	"sync"
-// 	"time"
-// )
-
-// type DBConnection struct {
-// 	ID        string
-// 	CreatedAt time.Time
-// 	LastUsed  time.Time
-// }
-
-// type DBConnectionPool struct {
-// 	connections chan *DBConnection
-// 	maxSize     int
-// 	timeout     time.Duration
-// 	closed      bool
-// 	mu          sync.Mutex
-// }
-
-// func NewDBConnectionPool(maxSize int, timeout time.Duration) *DBConnectionPool {
-// 	pool := &DBConnectionPool{
-// 		connections: make(chan *DBConnection, maxSize),
-// 		maxSize:     maxSize,
-// 		timeout:     timeout,
-// 	}
-
-// 	// Pre-populate the pool
-// 	for i := 0; i < maxSize; i++ {
-// 		conn := &DBConnection{
-// 			ID:        fmt.Sprintf("db-conn-%d", i+1),
-// 			CreatedAt: time.Now(),
-// 			LastUsed:  time.Now(),
-// 		}
-// 		pool.connections <- conn
-// 	}
-
-// 	return pool
-// }
-
-// func (p *DBConnectionPool) Get() *DBConnection {
-// 	select {
-// 	case conn := <-p.connections:
-// 		conn.LastUsed = time.Now()
-// 		fmt.Printf("  Acquired connection: %s\n", conn.ID)
-// 		return conn
-// 	case <-time.After(p.timeout):
-// 		fmt.Println("  Timeout waiting for connection")
-// 		return nil
-// 	}
-// }
-
-// func (p *DBConnectionPool) Put(conn *DBConnection) {
-// 	if conn == nil {
-// 		return
-// 	}
-
-// 	p.mu.Lock()
-// 	defer p.mu.Unlock()
-
-// 	if p.closed {
-// 		return
-// 	}
-
-// 	// Check if connection is still healthy
-// 	if time.Since(conn.LastUsed) > p.timeout {
-// 		fmt.Printf("  Discarding stale connection: %s\n", conn.ID)
-// 		return
-// 	}
-
-// 	select {
-// 	case p.connections <- conn:
-// 		fmt.Printf("  Returned connection: %s\n", conn.ID)
-// 	default:
-// 		fmt.Printf("  Pool full, discarding connection: %s\n", conn.ID)
-// 	}
-// }
-
-// func (p *DBConnectionPool) Close() {
-// 	p.mu.Lock()
-// 	defer p.mu.Unlock()
-// 	p.closed = true
-// 	close(p.connections)
-// }
-
 package pool
 
 import (
-	"fmt"
-	"sync"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
 	"time"
+
+	respool "github.com/Kama001/goadvanced/generics/resourcepoling/pool"
 )
 
 type DBConnection struct {
-	ID        int
-	CreatedAt time.Time
-	LastUsed  time.Time
+	ID int
+	// Conn is the database session behind the connection. It is nil for
+	// placeholder connections that only stand in for one.
+	Conn *sql.Conn
 }
 
-type DBConnectionPool struct {
-	mu          sync.Mutex
-	connections chan *DBConnection
-	closed      bool
-	timeout     time.Duration
-	maxSize     int
+// GetID returns the connection's ID.
+func (c *DBConnection) GetID() int {
+	return c.ID
 }
 
-func New(maxSize int, timeout time.Duration) *DBConnectionPool {
-	p := DBConnectionPool{
-		timeout:     timeout,
-		connections: make(chan *DBConnection, maxSize),
-		maxSize:     maxSize,
-	}
-	for i := 0; i < maxSize; i++ {
-		dbConn := DBConnection{
-			ID:        i,
-			CreatedAt: time.Now(),
-			LastUsed:  time.Now(),
-		}
-		p.connections <- &dbConn
+// Ping checks the session behind c is still usable.
+func (c *DBConnection) Ping(ctx context.Context) error {
+	if c.Conn == nil {
+		return nil
 	}
-	return &p
+	return c.Conn.PingContext(ctx)
 }
 
-func (p *DBConnectionPool) Get() *DBConnection {
-	select {
-	case conn := <-p.connections:
-		conn.LastUsed = time.Now()
-		fmt.Printf("Acquired connection: %d\n", conn.ID)
-		return conn
-	// waits for p.timeout and sends cur
-	// time to a channel
-	case <-time.After(p.timeout):
-		fmt.Println("timeout waiting for connection")
+// Close closes the session behind c. The driver connection is discarded
+// rather than handed back to the *sql.DB's own idle pool.
+func (c *DBConnection) Close() error {
+	if c.Conn == nil {
 		return nil
 	}
+	err := c.Conn.Raw(func(any) error { return driver.ErrBadConn })
+	if errors.Is(err, driver.ErrBadConn) {
+		return nil
+	}
+	return err
 }
 
-func (p *DBConnectionPool) Put(conn *DBConnection) {
-	if conn == nil {
-		return
-	}
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	if p.closed {
-		return
-	}
-	if time.Since(conn.LastUsed) > p.timeout {
-		newConn := DBConnection{
-			ID:        conn.ID,
-			CreatedAt: time.Now(),
-			LastUsed:  time.Now(),
+// Factory opens the sessions behind pooled connections.
+type Factory interface {
+	Open(ctx context.Context) (*sql.Conn, error)
+}
+
+// SQLFactory opens sessions from a *sql.DB.
+type SQLFactory struct {
+	DB *sql.DB
+}
+
+func (f *SQLFactory) Open(ctx context.Context) (*sql.Conn, error) {
+	return f.DB.Conn(ctx)
+}
+
+// NewWithFactory creates a pool of at most max connections, each backed by
+// a session opened with factory, and opens initial of them up front.
+// Sessions are pinged before they are lent out. A broken one is closed and
+// re-dialed lazily by the next Get that needs it, backing off from 100ms
+// up to 5s while dials keep failing; see respool.Pool.SetCreateBackoff.
+func NewWithFactory(initial, max int, factory Factory) (*respool.Pool[*DBConnection, int], error) {
+	var nextID atomic.Int64
+	open := func(ctx context.Context) (*DBConnection, error) {
+		session, err := factory.Open(ctx)
+		if err != nil {
+			return nil, err
 		}
-		p.connections <- &newConn
-		fmt.Printf("Discarding stale connection: %d\n", conn.ID)
-		return
+		return &DBConnection{ID: int(nextID.Add(1)) - 1, Conn: session}, nil
 	}
-	select {
-	case p.connections <- conn:
-		fmt.Printf("  Returned connection: %d\n", conn.ID)
-	default:
-		fmt.Printf("  Pool full, discarding connection: %d\n", conn.ID)
+	p, err := respool.New(open, initial, max)
+	if err != nil {
+		return nil, err
 	}
+	p.SetTargetIdle(0)
+	p.SetCreateBackoff(100*time.Millisecond, 5*time.Second)
+	p.SetHealthCheck(func(ctx context.Context, conn *DBConnection) error {
+		return conn.Ping(ctx)
+	})
+	return p, nil
 }
 
-func (p *DBConnectionPool) Close() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.closed = true
-	close(p.connections)
+// Classify sorts errors from DoWithRetry callbacks. Errors that mean the
+// session is unusable, such as driver.ErrBadConn, are FatalToResource so
+// the connection is discarded and the call retried on another one.
+func Classify(err error) respool.ErrorClass {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return respool.FatalToResource
+	}
+	return respool.Terminal
 }
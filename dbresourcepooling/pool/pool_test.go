@@ -0,0 +1,187 @@
+package pool
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	respool "github.com/Kama001/goadvanced/generics/resourcepoling/pool"
+	"test/pool/fakedb"
+)
+
+func TestSQLFactory(t *testing.T) {
+	d := fakedb.New()
+	db := d.OpenDB()
+	defer db.Close()
+
+	p, err := NewWithFactory(2, 2, &SQLFactory{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.OpenConns() != 2 {
+		t.Fatalf("expected 2 open sessions, got %d", d.OpenConns())
+	}
+
+	conn, err := p.Get(context.Background())
+	if err != nil || conn.Conn == nil {
+		t.Fatal("expected a connection with a session")
+	}
+	ctx := context.Background()
+	if _, err := conn.Conn.ExecContext(ctx, "SET greeting ?", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	var value string
+	if err := conn.Conn.QueryRowContext(ctx, "GET greeting").Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	if value != "hello" {
+		t.Fatalf("expected hello, got %q", value)
+	}
+	p.Put(conn)
+
+	p.Close()
+	if d.OpenConns() != 0 {
+		t.Fatalf("expected Close to release all sessions, got %d open", d.OpenConns())
+	}
+}
+
+func TestStaleSessionIsReplaced(t *testing.T) {
+	d := fakedb.New()
+	db := d.OpenDB()
+	defer db.Close()
+
+	p, err := NewWithFactory(1, 1, &SQLFactory{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	conn, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.BreakConns()
+	p.Put(conn)
+	if d.Opened() != 1 {
+		t.Fatalf("expected broken session to be re-dialed lazily, opened %d", d.Opened())
+	}
+
+	conn, err = p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("expected a replacement connection, got %s", err)
+	}
+	if d.Opened() != 2 {
+		t.Fatalf("expected Get to re-dial the broken session, opened %d", d.Opened())
+	}
+	if err := conn.Ping(context.Background()); err != nil {
+		t.Fatalf("expected replacement session to be healthy, got %s", err)
+	}
+	p.Put(conn)
+}
+
+func TestReconnectBacksOff(t *testing.T) {
+	d := fakedb.New()
+	db := d.OpenDB()
+	defer db.Close()
+
+	p, err := NewWithFactory(1, 1, &SQLFactory{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetCreateBackoff(50*time.Millisecond, time.Second)
+	p.SetAcquireTimeout(200 * time.Millisecond)
+
+	conn, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetOpenError(errors.New("connection refused"))
+	p.Discard(conn)
+
+	if _, err := p.Get(context.Background()); err == nil {
+		t.Fatal("expected Get to fail while the database refuses dials")
+	}
+	d.SetOpenError(nil)
+	start := time.Now()
+	conn, err = p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("expected reconnect after the backoff, got %s", err)
+	}
+	if time.Since(start) < 40*time.Millisecond {
+		t.Fatal("expected Get to wait out the reconnect backoff")
+	}
+	p.Put(conn)
+}
+
+func TestRedialHonorsGetContext(t *testing.T) {
+	d := fakedb.New()
+	db := d.OpenDB()
+	defer db.Close()
+
+	p, err := NewWithFactory(1, 1, &SQLFactory{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	conn, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Discard(conn)
+	d.SetOpenDelay(time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the re-dial to stop at the Get deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected Get to return at its deadline, took %s", elapsed)
+	}
+
+	d.SetOpenDelay(0)
+	conn, err = p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("expected a canceled re-dial not to trigger backoff, got %s", err)
+	}
+	p.Put(conn)
+}
+
+func TestClassifyDiscardsBadConns(t *testing.T) {
+	d := fakedb.New()
+	db := d.OpenDB()
+	defer db.Close()
+
+	p, err := NewWithFactory(1, 1, &SQLFactory{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	var ids []int
+	policy := respool.RetryPolicy{MaxAttempts: 2, Classify: Classify}
+	err = p.DoWithRetry(context.Background(), func(conn *DBConnection) error {
+		ids = append(ids, conn.ID)
+		if len(ids) == 1 {
+			return driver.ErrBadConn
+		}
+		return nil
+	}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] == ids[1] {
+		t.Fatalf("expected the retry to run on a fresh connection, got %v", ids)
+	}
+	if d.OpenConns() != 1 {
+		t.Fatalf("expected the bad session to be closed, got %d open", d.OpenConns())
+	}
+	if Classify(errors.New("syntax error")) != respool.Terminal {
+		t.Fatal("expected other errors to be terminal")
+	}
+}
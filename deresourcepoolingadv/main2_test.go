@@ -2,43 +2,38 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/Kama001/goadvanced/generics/resourcepoling/pool"
 )
 
 func TestPool(t *testing.T) {
 	dbFactory := &DBFactory{}
-	pool, err := New[*DBConnection](dbFactory, 2, 3*time.Second, 5, 20*time.Second, 5*time.Second)
+	dbPool, err := pool.New(dbFactory.Create, 2, 5)
 	if err != nil {
-		fmt.Println(err)
+		t.Fatal(err)
 	}
-	fmt.Println("pool size is", pool.Len())
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer dbPool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
-	count := 0
 	var wg sync.WaitGroup
 	for i := 0; i < 5; i++ {
 		wg.Add(1)
-		go func(i int) {
+		go func() {
 			defer wg.Done()
-			res, err := pool.Get(ctx)
+			err := dbPool.DoTx(ctx, func(res *DBConnection) error {
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			})
 			if err != nil {
-				count++
+				t.Error(err)
 			}
-			fmt.Println("pool size is", pool.Len())
-			fmt.Printf("go routine %d got the connectiond %d\n", i, res.GetID())
-			time.Sleep(10 * time.Second)
-			pool.Put(res)
-			fmt.Printf("go routine %d put the connectiond %d\n", i, res.GetID())
-		}(i)
+		}()
 	}
 	wg.Wait()
-	fmt.Println("pool size is", pool.Len())
-	if count == pool.Len() {
-		t.Logf("pool sizes matched")
-	} else {
-		t.Fatalf("pool sizes didn't match")
+	if s := dbPool.Stats(); s.InUse != 0 || s.OpenConnections > 5 {
+		t.Fatalf("expected every connection back and at most 5 open, got %+v", s)
 	}
 }
@@ -1,3 +1,3 @@
-module resourcepooling
+module github.com/Kama001/goadvanced/generics/resourcepoling
 
 go 1.24.0
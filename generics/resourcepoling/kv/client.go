@@ -0,0 +1,160 @@
+package kv
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by Get for keys that were never set.
+	ErrNotFound = errors.New("key not found")
+
+	// ErrConnClosed is returned by requests on a closed or broken Conn.
+	ErrConnClosed = errors.New("connection is closed")
+)
+
+var nextID atomic.Int64
+
+// Conn is a client connection to a Server. It has the Close and GetID
+// methods pool.Resource asks for, so it can be pooled directly. A Conn is
+// not safe for concurrent requests; the pool hands it to one borrower at a
+// time.
+type Conn struct {
+	id int
+	nc net.Conn
+	r  *bufio.Reader
+
+	mu     sync.Mutex
+	broken bool
+}
+
+// Dial connects to the server at addr.
+func Dial(ctx context.Context, addr string) (*Conn, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(nc), nil
+}
+
+// NewConn wraps an already established connection to a Server.
+func NewConn(nc net.Conn) *Conn {
+	return &Conn{
+		id: int(nextID.Add(1)),
+		nc: nc,
+		r:  bufio.NewReader(nc),
+	}
+}
+
+// Ping checks the server still answers on this connection.
+func (c *Conn) Ping(ctx context.Context) error {
+	reply, err := c.do(ctx, "PING")
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected ping reply %q", reply)
+	}
+	return nil
+}
+
+// Set stores value under key. Neither may contain a newline and key may
+// not contain a space.
+func (c *Conn) Set(ctx context.Context, key, value string) error {
+	reply, err := c.do(ctx, "SET "+key+" "+value)
+	if err != nil {
+		return err
+	}
+	if reply != "OK" {
+		return fmt.Errorf("set %s: %s", key, reply)
+	}
+	return nil
+}
+
+// Get returns the value stored under key, or ErrNotFound.
+func (c *Conn) Get(ctx context.Context, key string) (string, error) {
+	reply, err := c.do(ctx, "GET "+key)
+	if err != nil {
+		return "", err
+	}
+	if reply == "NOTFOUND" {
+		return "", ErrNotFound
+	}
+	value, ok := strings.CutPrefix(reply, "VALUE ")
+	if !ok {
+		return "", fmt.Errorf("get %s: %s", key, reply)
+	}
+	return value, nil
+}
+
+// do sends one request line and reads the reply, honouring ctx's deadline
+// and cancellation. Any I/O error leaves the Conn broken, since the reply
+// stream can no longer be trusted.
+func (c *Conn) do(ctx context.Context, line string) (string, error) {
+	if c.Broken() {
+		return "", ErrConnClosed
+	}
+	deadline, _ := ctx.Deadline()
+	c.nc.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		c.nc.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	_, err := c.nc.Write([]byte(line + "\n"))
+	var reply string
+	if err == nil {
+		reply, err = c.r.ReadString('\n')
+	}
+	if err != nil {
+		c.markBroken()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		// The socket deadline can fire a moment before ctx notices.
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return "", context.DeadlineExceeded
+		}
+		return "", err
+	}
+	return strings.TrimRight(reply, "\r\n"), nil
+}
+
+// Broken reports whether the connection failed or was closed.
+func (c *Conn) Broken() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.broken
+}
+
+func (c *Conn) markBroken() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.broken = true
+}
+
+// Close says QUIT to the server, without waiting for the reply, and closes
+// the socket.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	wasBroken := c.broken
+	c.broken = true
+	c.mu.Unlock()
+	if !wasBroken {
+		c.nc.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+		c.nc.Write([]byte("QUIT\n"))
+	}
+	return c.nc.Close()
+}
+
+func (c *Conn) GetID() int {
+	return c.id
+}
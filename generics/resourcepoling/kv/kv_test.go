@@ -0,0 +1,229 @@
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kama001/goadvanced/generics/resourcepoling/kv"
+	"github.com/Kama001/goadvanced/generics/resourcepoling/pool"
+)
+
+func newServer(t *testing.T) *kv.Server {
+	t.Helper()
+	s, err := kv.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func dial(t *testing.T, s *kv.Server) *kv.Conn {
+	t.Helper()
+	c, err := kv.Dial(context.Background(), s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestRoundTrip(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s)
+	ctx := context.Background()
+
+	if err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "greeting"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := c.Set(ctx, "greeting", "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	value, err := dial(t, s).Get(ctx, "greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "hello world" {
+		t.Fatalf("expected hello world, got %q", value)
+	}
+}
+
+func TestLatencyTimesOut(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s)
+	s.SetLatency(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Ping(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if !c.Broken() {
+		t.Fatal("expected a timed out connection to be broken")
+	}
+}
+
+func TestDropConns(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s)
+	s.DropConns()
+
+	if err := c.Ping(context.Background()); err == nil {
+		t.Fatal("expected Ping on a dropped connection to fail")
+	}
+	if err := dial(t, s).Ping(context.Background()); err != nil {
+		t.Fatalf("expected new dials to work after DropConns, got %s", err)
+	}
+}
+
+func TestRefuse(t *testing.T) {
+	s := newServer(t)
+	if err := s.Refuse(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Dial(context.Background(), s.Addr()); err == nil {
+		t.Fatal("expected dial to be refused")
+	}
+	if err := s.Refuse(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := dial(t, s).Ping(context.Background()); err != nil {
+		t.Fatalf("expected dial to work again, got %s", err)
+	}
+}
+
+func TestPooledConns(t *testing.T) {
+	s := newServer(t)
+	factory := func(ctx context.Context) (*kv.Conn, error) {
+		return kv.Dial(ctx, s.Addr())
+	}
+	p, err := pool.New(factory, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "id", "1"); err != nil {
+		t.Fatal(err)
+	}
+	p.Put(c)
+	p.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for s.Conns() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := s.Conns(); n != 0 {
+		t.Fatalf("expected closing the pool to hang up every connection, %d left", n)
+	}
+}
+
+func newPool(t *testing.T, s *kv.Server, initial, max int) *pool.Pool[*kv.Conn, int] {
+	t.Helper()
+	factory := func(ctx context.Context) (*kv.Conn, error) {
+		return kv.Dial(ctx, s.Addr())
+	}
+	p, err := pool.New(factory, initial, max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestPoolReplacesDroppedConns(t *testing.T) {
+	s := newServer(t)
+	p := newPool(t, s, 2, 2)
+	p.SetBorrowCheck(func(c *kv.Conn) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		return c.Ping(ctx)
+	})
+	s.DropConns()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Put(c)
+	if err := c.Set(ctx, "id", "1"); err != nil {
+		t.Fatalf("expected a working replacement connection, got %s", err)
+	}
+	if n := p.Stats().HealthCheckFailed; n == 0 {
+		t.Fatal("expected the dropped connections to fail the borrow check")
+	}
+}
+
+func TestPoolReportsRefusedDials(t *testing.T) {
+	s := newServer(t)
+	p := newPool(t, s, 1, 2)
+	errs := make(chan error, 16)
+	p.SetOnFactoryError(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	if err := s.Refuse(true); err != nil {
+		t.Fatal(err)
+	}
+	p.SetTargetIdle(2)
+
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("expected the refused dial to reach the factory error hook")
+	}
+	if err := s.Refuse(false); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for p.Stats().Idle < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := p.Stats().Idle; n != 2 {
+		t.Fatalf("expected the refill worker to recover to 2 idle, got %d", n)
+	}
+}
+
+func TestPoolAcquireTimeoutUnderLatency(t *testing.T) {
+	s := newServer(t)
+	p := newPool(t, s, 1, 1)
+	p.SetAcquireTimeout(50 * time.Millisecond)
+	c, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetLatency(300 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		c.Ping(ctx)
+		p.Put(c)
+	}()
+
+	if _, err := p.Get(context.Background()); !errors.Is(err, pool.ErrAcquireTimeout) {
+		t.Fatalf("expected ErrAcquireTimeout while the only conn is slow, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Get(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, pool.ErrAcquireTimeout) {
+		t.Fatalf("expected the shorter ctx deadline to win, got %v", err)
+	}
+	<-done
+}
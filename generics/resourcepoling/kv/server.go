@@ -0,0 +1,200 @@
+// Package kv is a tiny line-protocol key-value server and client for
+// exercising pools over real localhost sockets.
+//
+// Each request is one line and gets one line back:
+//
+//	PING          -> PONG
+//	SET key value -> OK
+//	GET key       -> VALUE value | NOTFOUND
+//	QUIT          -> BYE, then the server hangs up
+package kv
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an in-process KV server listening on a localhost port. Its
+// fault knobs let tests simulate a slow, flaky or unreachable backend.
+type Server struct {
+	mu       sync.Mutex
+	ln       net.Listener
+	addr     string
+	data     map[string]string
+	conns    map[net.Conn]struct{}
+	latency  time.Duration
+	refusing bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on a free localhost port.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ln:    ln,
+		addr:  ln.Addr().String(),
+		data:  make(map[string]string),
+		conns: make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve(ln)
+	return s, nil
+}
+
+// Addr returns the host:port clients should dial.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// SetLatency delays every reply by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// DropConns hangs up on every connected client, as if the server had
+// restarted. New dials still succeed.
+func (s *Server) DropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// Refuse stops listening so new dials are refused, and drops existing
+// clients. Refuse(false) listens again on the same address.
+func (s *Server) Refuse(on bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || on == s.refusing {
+		return nil
+	}
+	if on {
+		s.refusing = true
+		for c := range s.conns {
+			c.Close()
+		}
+		return s.ln.Close()
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.refusing = false
+	s.ln = ln
+	s.wg.Add(1)
+	go s.serve(ln)
+	return nil
+}
+
+// Conns returns how many clients are currently connected.
+func (s *Server) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Close stops the server and hangs up on every client.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	var err error
+	if !s.refusing {
+		err = s.ln.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed || s.refusing {
+			s.mu.Unlock()
+			c.Close()
+			continue
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		reply, quit := s.exec(strings.TrimRight(line, "\r\n"))
+		s.mu.Lock()
+		latency := s.latency
+		s.mu.Unlock()
+		if latency > 0 {
+			time.Sleep(latency)
+		}
+		if _, err := c.Write([]byte(reply + "\n")); err != nil || quit {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(line string) (reply string, quit bool) {
+	cmd, args, _ := strings.Cut(line, " ")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch strings.ToUpper(cmd) {
+	case "PING":
+		return "PONG", false
+	case "SET":
+		key, value, ok := strings.Cut(args, " ")
+		if !ok || key == "" {
+			return "ERR usage: SET key value", false
+		}
+		s.data[key] = value
+		return "OK", false
+	case "GET":
+		if args == "" {
+			return "ERR usage: GET key", false
+		}
+		value, ok := s.data[args]
+		if !ok {
+			return "NOTFOUND", false
+		}
+		return "VALUE " + value, false
+	case "QUIT":
+		return "BYE", true
+	}
+	return "ERR unknown command " + cmd, false
+}
@@ -4,22 +4,30 @@ import (
 	"context"
 	"log"
 	"math/rand"
-	"resourcepooling/pool"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/Kama001/goadvanced/generics/resourcepoling/pool"
 )
 
 type DBConnection struct {
 	ID int
 }
 
+func (c *DBConnection) GetID() int {
+	return c.ID
+}
+
 func (c *DBConnection) Close() error {
 	log.Printf("Closing connection %d\n", c.ID)
 	return nil
 }
 
-func factory() (*DBConnection, error) {
-	conn := &DBConnection{ID: rand.Intn(1000)}
+var nextID atomic.Int64
+
+func factory(ctx context.Context) (*DBConnection, error) {
+	conn := &DBConnection{ID: int(nextID.Add(1))}
 	log.Printf("Creating new connection %d\n", conn.ID)
 	return conn, nil
 }
@@ -56,6 +64,10 @@ func main() {
 	}
 	wg.Wait()
 
+	s := p.Stats()
+	log.Printf("Stats: open %d/%d, idle %d, in use %d, %d gets waited %s in total",
+		s.OpenConnections, s.MaxOpenConnections, s.Idle, s.InUse, s.WaitCount, s.WaitDuration)
+
 	// 3. Close the pool
 	log.Println("All workers finished. Closing the pool...")
 	p.Close()
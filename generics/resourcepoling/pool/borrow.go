@@ -0,0 +1,94 @@
+package pool
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+type borrowerKey struct{}
+
+// WithBorrower tags ctx with the name of whoever is borrowing a resource,
+// so slow borrows and Inspect can say who holds it.
+func WithBorrower(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, borrowerKey{}, name)
+}
+
+// ResourceStats is a snapshot of one open resource's borrow history.
+type ResourceStats[ID comparable] struct {
+	ID            ID
+	CreatedAt     time.Time
+	LastUsed      time.Time
+	InUse         bool
+	Borrower      string // current borrower, empty when idle
+	Borrows       int
+	BorrowedTime  time.Duration // total time spent checked out
+	LongestBorrow time.Duration
+}
+
+// SetSlowBorrowThreshold logs every resource that is held for longer than
+// d, once it is put back or discarded. Zero disables the log.
+func (p *Pool[T, ID]) SetSlowBorrowThreshold(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.slowBorrow = d
+}
+
+// Inspect returns the stats of every open resource, oldest first. Checked
+// out resources count their current borrow as well.
+func (p *Pool[T, ID]) Inspect() []ResourceStats[ID] {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	stats := make([]ResourceStats[ID], 0, len(p.entries))
+	for id, e := range p.entries {
+		s := ResourceStats[ID]{
+			ID:            id,
+			CreatedAt:     e.createdAt,
+			LastUsed:      e.lastUsed,
+			Borrows:       e.uses,
+			BorrowedTime:  e.borrowedTime,
+			LongestBorrow: e.longestBorrow,
+		}
+		if !e.borrowedAt.IsZero() {
+			held := now.Sub(e.borrowedAt)
+			s.InUse = true
+			s.Borrower = e.borrower
+			s.BorrowedTime += held
+			s.LongestBorrow = max(s.LongestBorrow, held)
+		}
+		stats = append(stats, s)
+	}
+	slices.SortFunc(stats, func(a, b ResourceStats[ID]) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return stats
+}
+
+// borrow records who is borrowing res, which Get is about to hand out,
+// and restarts its borrow clock so time spent waiting for weight is not
+// counted.
+func (p *Pool[T, ID]) borrow(ctx context.Context, res T) {
+	borrower, _ := ctx.Value(borrowerKey{}).(string)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.entries[res.GetID()]; e != nil {
+		e.borrowedAt = time.Now()
+		e.borrower = borrower
+	}
+}
+
+// giveBack ends the current borrow of the resource with id. It must be
+// called with p.mu held.
+func (p *Pool[T, ID]) giveBack(id ID, e *entry) {
+	held := time.Since(e.borrowedAt)
+	e.borrowedTime += held
+	e.longestBorrow = max(e.longestBorrow, held)
+	if p.slowBorrow > 0 && held > p.slowBorrow {
+		borrower := e.borrower
+		if borrower == "" {
+			borrower = "unknown"
+		}
+		p.logger.Printf("Slow borrow: resource %v held %s by %s\n", id, held.Round(time.Millisecond), borrower)
+	}
+	e.borrowedAt = time.Time{}
+	e.borrower = ""
+}
@@ -0,0 +1,65 @@
+package pool
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	p := newDBPool(t, 2, 2)
+	p.SetSlowBorrowThreshold(30 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		r, err := p.Get(WithBorrower(context.Background(), "report"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Put(r)
+	}
+
+	var out strings.Builder
+	p.SetLogOutput(&out)
+	r, err := p.Get(WithBorrower(context.Background(), "batch-job"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inUse := p.Inspect()
+	time.Sleep(50 * time.Millisecond)
+	p.Put(r)
+	if !strings.Contains(out.String(), "by batch-job") {
+		t.Fatalf("expected a slow borrow log tagged with the borrower, got %q", out.String())
+	}
+
+	var held ResourceStats[int]
+	for _, s := range inUse {
+		if s.ID == r.GetID() {
+			held = s
+		}
+	}
+	if !held.InUse || held.Borrower != "batch-job" {
+		t.Fatalf("expected resource %d in use by batch-job, got %+v", r.GetID(), held)
+	}
+
+	stats := p.Inspect()
+	if len(stats) != 2 || stats[0].ID != 1 {
+		t.Fatalf("expected 2 resources, oldest first, got %+v", stats)
+	}
+	borrows := 0
+	for _, s := range stats {
+		borrows += s.Borrows
+		if s.InUse {
+			t.Fatalf("expected resource %d to be idle", s.ID)
+		}
+		if s.ID == r.GetID() && s.LongestBorrow < 50*time.Millisecond {
+			t.Fatalf("expected a longest borrow of at least 50ms, got %s", s.LongestBorrow)
+		}
+		if s.BorrowedTime < s.LongestBorrow {
+			t.Fatalf("expected borrowed time %s to include the longest borrow %s", s.BorrowedTime, s.LongestBorrow)
+		}
+	}
+	if borrows != 4 {
+		t.Fatalf("expected 4 borrows in total, got %d", borrows)
+	}
+}
@@ -0,0 +1,252 @@
+package pool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Config holds pool settings that ops can tune without recompiling.
+// Durations use Go syntax, such as "30s" or "5m". As with the pool's
+// setters, zero disables a limit, and a zero ReaperInterval runs no reaper.
+//
+// MaxOpen can be lowered on a running pool but never raised past the value
+// the pool was created with, because that sizes the idle channel. Set it
+// to the most a reload may ever need and lower it from there.
+type Config struct {
+	Size           int
+	MaxOpen        int
+	MaxLifetime    time.Duration
+	MaxUses        int
+	IdleTimeout    time.Duration
+	ReaperInterval time.Duration
+}
+
+// FieldError reports an invalid config field.
+type FieldError struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (fe *FieldError) Error() string {
+	if fe.Value == "" {
+		return fmt.Sprintf("config: %s: %s", fe.Field, fe.Reason)
+	}
+	return fmt.Sprintf("config: %s=%q: %s", fe.Field, fe.Value, fe.Reason)
+}
+
+type configField struct {
+	json string
+	env  string
+	set  func(cfg *Config, value string) error
+}
+
+func intField(get func(cfg *Config) *int) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		*get(cfg) = n
+		return nil
+	}
+}
+
+func durationField(get func(cfg *Config) *time.Duration) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New(`not a duration, want something like "30s"`)
+		}
+		*get(cfg) = d
+		return nil
+	}
+}
+
+var configFields = []configField{
+	{"size", "SIZE", intField(func(cfg *Config) *int { return &cfg.Size })},
+	{"maxOpen", "MAX_OPEN", intField(func(cfg *Config) *int { return &cfg.MaxOpen })},
+	{"maxLifetime", "MAX_LIFETIME", durationField(func(cfg *Config) *time.Duration { return &cfg.MaxLifetime })},
+	{"maxUses", "MAX_USES", intField(func(cfg *Config) *int { return &cfg.MaxUses })},
+	{"idleTimeout", "IDLE_TIMEOUT", durationField(func(cfg *Config) *time.Duration { return &cfg.IdleTimeout })},
+	{"reaperInterval", "REAPER_INTERVAL", durationField(func(cfg *Config) *time.Duration { return &cfg.ReaperInterval })},
+}
+
+// LoadConfigJSON reads settings from a JSON object on top of cfg. Unknown
+// fields are rejected.
+func LoadConfigJSON(r io.Reader, cfg Config) (Config, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return cfg, fmt.Errorf("config: %w", err)
+	}
+	var errs []error
+	for _, f := range configFields {
+		msg, ok := raw[f.json]
+		if !ok {
+			continue
+		}
+		delete(raw, f.json)
+		value := string(msg)
+		if s, err := strconv.Unquote(value); err == nil {
+			value = s
+		}
+		if err := f.set(&cfg, value); err != nil {
+			errs = append(errs, &FieldError{Field: f.json, Value: value, Reason: err.Error()})
+		}
+	}
+	for name := range raw {
+		errs = append(errs, &FieldError{Field: name, Reason: "unknown field"})
+	}
+	if len(errs) > 0 {
+		return cfg, errors.Join(errs...)
+	}
+	return cfg, cfg.Validate()
+}
+
+// LoadConfigFile reads settings from the JSON file at path on top of cfg.
+func LoadConfigFile(path string, cfg Config) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+	return LoadConfigJSON(f, cfg)
+}
+
+// LoadConfigEnv reads settings from environment variables on top of cfg.
+// With prefix "DBPOOL", maxOpen is read from DBPOOL_MAX_OPEN.
+func LoadConfigEnv(prefix string, cfg Config) (Config, error) {
+	var errs []error
+	for _, f := range configFields {
+		name := prefix + "_" + f.env
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := f.set(&cfg, strings.TrimSpace(value)); err != nil {
+			errs = append(errs, &FieldError{Field: name, Value: value, Reason: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return cfg, errors.Join(errs...)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks the settings New needs, reporting every bad field.
+func (cfg Config) Validate() error {
+	var errs []error
+	if cfg.Size < 0 {
+		errs = append(errs, &FieldError{Field: "size", Value: strconv.Itoa(cfg.Size), Reason: "must not be negative"})
+	}
+	switch {
+	case cfg.MaxOpen <= 0:
+		errs = append(errs, &FieldError{Field: "maxOpen", Value: strconv.Itoa(cfg.MaxOpen), Reason: "must be positive"})
+	case cfg.MaxOpen < cfg.Size:
+		errs = append(errs, &FieldError{Field: "maxOpen", Value: strconv.Itoa(cfg.MaxOpen), Reason: "must be at least size"})
+	}
+	if cfg.MaxUses < 0 {
+		errs = append(errs, &FieldError{Field: "maxUses", Value: strconv.Itoa(cfg.MaxUses), Reason: "must not be negative"})
+	}
+	for _, d := range []struct {
+		field string
+		value time.Duration
+	}{
+		{"maxLifetime", cfg.MaxLifetime},
+		{"idleTimeout", cfg.IdleTimeout},
+		{"reaperInterval", cfg.ReaperInterval},
+	} {
+		if d.value < 0 {
+			errs = append(errs, &FieldError{Field: d.field, Value: d.value.String(), Reason: "must not be negative"})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewFromConfig creates a pool from cfg. MaxUses is enforced by the reaper
+// alongside IdleTimeout.
+func NewFromConfig[T Resource[ID], ID comparable](factory Factory[T], cfg Config) (*Pool[T, ID], error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	p, err := New(factory, cfg.Size, cfg.MaxOpen)
+	if err != nil {
+		return nil, err
+	}
+	p.SetMaxLifetime(cfg.MaxLifetime)
+	p.SetMaxIdleTime(cfg.IdleTimeout)
+	p.SetMaxUses(cfg.MaxUses)
+	p.SetReapInterval(cfg.ReaperInterval)
+	return p, nil
+}
+
+// Reconfigure applies cfg to a running pool. Size becomes the number of
+// idle resources the refiller keeps ready. MaxOpen can shrink, closing
+// surplus resources as they come back, but cannot grow past the value the
+// pool was created with. A cfg that tries is rejected as a whole, so the
+// pool never runs with half a config.
+func (p *Pool[T, ID]) Reconfigure(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	if limit := cap(p.resources); cfg.MaxOpen > limit {
+		p.mu.Unlock()
+		return &FieldError{Field: "maxOpen", Value: strconv.Itoa(cfg.MaxOpen), Reason: fmt.Sprintf("cannot grow past %d on a running pool", limit)}
+	}
+	p.maxLifetime = cfg.MaxLifetime
+	p.maxIdleTime = cfg.IdleTimeout
+	p.maxUses = cfg.MaxUses
+	p.max = cfg.MaxOpen
+	p.wake()
+	p.mu.Unlock()
+	p.SetReapInterval(cfg.ReaperInterval)
+	p.SetTargetIdle(cfg.Size)
+	return nil
+}
+
+// ReloadOnSIGHUP calls load and applies the result to the pool each time
+// the process receives SIGHUP, until stop is called. A config that fails
+// to load or apply is logged and the pool keeps its current settings; see
+// Reconfigure for why MaxOpen cannot grow. stop may be called more than
+// once.
+func (p *Pool[T, ID]) ReloadOnSIGHUP(load func() (Config, error)) (stop func()) {
+	sig := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-sig:
+				cfg, err := load()
+				if err != nil {
+					p.logger.Printf("config reload failed: %s\n", err)
+					continue
+				}
+				if err := p.Reconfigure(cfg); err != nil {
+					p.logger.Printf("config reload: %s\n", err)
+					continue
+				}
+				p.logger.Println("config reloaded")
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sig)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfigJSON(strings.NewReader(`{
+		"size": 2,
+		"maxOpen": 5,
+		"maxLifetime": "30m",
+		"maxUses": 100,
+		"idleTimeout": "5m",
+		"reaperInterval": "30s"
+	}`), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{Size: 2, MaxOpen: 5, MaxLifetime: 30 * time.Minute, MaxUses: 100, IdleTimeout: 5 * time.Minute, ReaperInterval: 30 * time.Second}
+	if cfg != want {
+		t.Fatalf("expected %+v, got %+v", want, cfg)
+	}
+
+	t.Setenv("DBPOOL_MAX_OPEN", "8")
+	t.Setenv("DBPOOL_IDLE_TIMEOUT", "1m")
+	cfg, err = LoadConfigEnv("DBPOOL", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxOpen != 8 || cfg.IdleTimeout != time.Minute || cfg.Size != 2 {
+		t.Fatalf("expected env to override maxOpen and idleTimeout, got %+v", cfg)
+	}
+
+	_, err = LoadConfigJSON(strings.NewReader(`{"size": 0, "maxOpen": "lots", "maxLifetime": "30", "idle": "5m"}`), cfg)
+	fields := map[string]bool{}
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fe *FieldError
+		if !errors.As(e, &fe) {
+			t.Fatalf("expected a FieldError, got %v", e)
+		}
+		fields[fe.Field] = true
+	}
+	for _, f := range []string{"maxOpen", "maxLifetime", "idle"} {
+		if !fields[f] {
+			t.Fatalf("expected an error for %s, got %v", f, err)
+		}
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	p, err := NewFromConfig(dbConnFactory(), Config{
+		Size: 1, MaxOpen: 2, MaxLifetime: time.Hour, IdleTimeout: time.Hour, ReaperInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	err = p.Reconfigure(Config{
+		Size: 2, MaxOpen: 2, MaxLifetime: time.Millisecond, MaxUses: 5, IdleTimeout: time.Hour, ReaperInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetID() == 1 {
+		t.Fatal("expected the new max lifetime to evict resource 1")
+	}
+	p.Put(r)
+
+	err = p.Reconfigure(Config{
+		Size: 1, MaxOpen: 4, MaxLifetime: time.Hour, IdleTimeout: time.Hour, ReaperInterval: time.Hour,
+	})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "maxOpen" {
+		t.Fatalf("expected growing maxOpen to be rejected, got %v", err)
+	}
+	if s := p.Stats(); s.MaxOpenConnections != 2 {
+		t.Fatalf("expected maxOpen to stay at 2, got %+v", s)
+	}
+	if p.maxLifetime != time.Millisecond {
+		t.Fatalf("expected the rejected config not to be applied, max lifetime is %s", p.maxLifetime)
+	}
+}
+
+func TestConfigZeroDisables(t *testing.T) {
+	cfg, err := LoadConfigJSON(strings.NewReader(`{
+		"size": 0,
+		"maxOpen": 2,
+		"maxLifetime": "0s",
+		"idleTimeout": "0s",
+		"reaperInterval": "0s"
+	}`), Config{})
+	if err != nil {
+		t.Fatalf("expected zero to be accepted as disabled, got %v", err)
+	}
+	p, err := NewFromConfig(dbConnFactory(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if s := p.Stats(); s.OpenConnections != 0 {
+		t.Fatalf("expected no resources up front, got %+v", s)
+	}
+
+	cfg.MaxLifetime = -time.Second
+	cfg.MaxOpen = 0
+	err = cfg.Validate()
+	fields := map[string]bool{}
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fe *FieldError
+		if errors.As(e, &fe) {
+			fields[fe.Field] = true
+		}
+	}
+	if !fields["maxLifetime"] || !fields["maxOpen"] {
+		t.Fatalf("expected a negative max lifetime and zero maxOpen to be rejected, got %v", err)
+	}
+}
+
+func TestConfigMaxUsesKeepsIdleTimeout(t *testing.T) {
+	p, err := NewFromConfig(dbConnFactory(), Config{
+		Size: 2, MaxOpen: 2, MaxLifetime: time.Hour, MaxUses: 2, IdleTimeout: 10 * time.Millisecond, ReaperInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetTargetIdle(0)
+
+	// Idle resources are handed out in turn, so three borrows use one
+	// resource twice and the other once.
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		r, err := p.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Put(r)
+	}
+	time.Sleep(20 * time.Millisecond)
+	p.reap()
+	if s := p.Stats(); s.MaxUsesClosed != 1 || s.MaxIdleClosed != 1 {
+		t.Fatalf("expected one max uses and one idle timeout eviction, got %+v", s)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package pool
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	cfg := Config{Size: 1, MaxOpen: 2, MaxLifetime: time.Hour, IdleTimeout: time.Hour, ReaperInterval: time.Hour}
+	p, err := NewFromConfig(dbConnFactory(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	loaded := make(chan struct{}, 1)
+	cfg.MaxOpen = 1
+	stop := p.ReloadOnSIGHUP(func() (Config, error) {
+		loaded <- struct{}{}
+		return cfg, nil
+	})
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-loaded:
+	case <-time.After(time.Second):
+		t.Fatal("expected SIGHUP to reload the config")
+	}
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().MaxOpenConnections != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the reloaded maxOpen to be applied, got %+v", p.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+}
@@ -0,0 +1,153 @@
+package pool
+
+import (
+	"context"
+	"time"
+)
+
+// tokenBucket refills at rate tokens per second up to burst tokens.
+// It is not safe for concurrent use, Pool guards it with mu.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// take removes a token and returns 0 if one is available, otherwise it
+// returns how long until the next token is due.
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// createBackoff spaces out creations after consecutive factory failures.
+type createBackoff struct {
+	base     time.Duration
+	max      time.Duration
+	failures int
+	next     time.Time
+}
+
+func (b *createBackoff) failed(now time.Time) {
+	if b.base <= 0 {
+		return
+	}
+	d := b.base << b.failures
+	if d > b.max || d <= 0 {
+		d = b.max
+	}
+	b.failures++
+	b.next = now.Add(d)
+}
+
+// SetCreateBackoff makes Get wait before creating a resource again after
+// the factory fails, so a backend that is down is not hammered with dials.
+// The wait starts at base and doubles with each consecutive failure, up to
+// max. Failures caused by the Get giving up do not count. A zero base
+// disables the backoff.
+func (p *Pool[T, ID]) SetCreateBackoff(base, max time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.backoff = createBackoff{base: base, max: max}
+}
+
+// SetMaxCreating caps how many factory calls Get can have in flight at
+// once. Zero means creations are only bounded by max.
+func (p *Pool[T, ID]) SetMaxCreating(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxCreating = n
+}
+
+// SetCreateRate limits the resources Get creates to rate per second,
+// allowing bursts of up to burst creations. A rate of zero removes the
+// limit.
+func (p *Pool[T, ID]) SetCreateRate(rate float64, burst int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if rate <= 0 {
+		p.createLimit = nil
+		return
+	}
+	p.createLimit = newTokenBucket(rate, burst)
+}
+
+// startCreate starts a background creation when there are more waiting
+// Gets than creations already in flight. The outcome is only sent on the
+// returned channel, to the Get that started it, while the resource itself
+// goes to the idle channel for whichever waiter is first. It must be
+// called with p.mu held and returns how long to wait before retrying when
+// the rate limit or the create backoff is hit.
+func (p *Pool[T, ID]) startCreate(ctx context.Context) (<-chan error, time.Duration) {
+	if p.creating >= p.waiting || p.open >= p.max {
+		return nil, 0
+	}
+	if p.maxCreating > 0 && p.creating >= p.maxCreating {
+		return nil, 0
+	}
+	if wait := time.Until(p.backoff.next); wait > 0 {
+		return nil, wait
+	}
+	if p.createLimit != nil {
+		if wait := p.createLimit.take(time.Now()); wait > 0 {
+			return nil, wait
+		}
+	}
+	p.creating++
+	p.open++
+	errc := make(chan error, 1)
+	go p.create(ctx, errc)
+	return errc, 0
+}
+
+// create runs the factory for a slot reserved by startCreate and reports
+// the outcome on errc. Either way waiters are woken, since a creation slot
+// is free again.
+func (p *Pool[T, ID]) create(ctx context.Context, errc chan<- error) {
+	res, err := p.factory(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.creating--
+	errc <- err
+	if err != nil {
+		if ctx.Err() == nil {
+			p.backoff.failed(time.Now())
+		}
+		p.release()
+		return
+	}
+	p.backoff.failures = 0
+	if p.closed {
+		p.release()
+		res.Close()
+		return
+	}
+	p.track(res).fresh = true
+	select {
+	case p.resources <- res:
+		p.wake()
+	default:
+		p.destroy(res)
+	}
+}
@@ -0,0 +1,163 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxCreating(t *testing.T) {
+	var inflight, peak, created atomic.Int32
+	factory := func(ctx context.Context) (*testResource, error) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			m := peak.Load()
+			if n <= m || peak.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		created.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return newTestResource(ctx)
+	}
+	p, err := New(factory, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetMaxCreating(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := p.Get(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+			p.Put(r)
+		}()
+	}
+	wg.Wait()
+
+	if n := peak.Load(); n != 1 {
+		t.Fatalf("expected at most 1 concurrent create, saw %d", n)
+	}
+	if n := created.Load(); n > 5 {
+		t.Fatalf("expected at most 5 creates, saw %d", n)
+	}
+}
+
+func TestCreateRate(t *testing.T) {
+	p, err := New(newTestResource, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetCreateRate(10, 1)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := p.Get(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("expected creates to be rate limited, took %s", elapsed)
+	}
+}
+
+func TestCreateErrorReachesWaiters(t *testing.T) {
+	factory := func(ctx context.Context) (*testResource, error) {
+		time.Sleep(50 * time.Millisecond)
+		return nil, errors.New("connection refused")
+	}
+	p, err := New(factory, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Get(context.Background()); err == nil {
+				t.Error("expected the create error")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCreateErrorOnlyFailsItsWaiter(t *testing.T) {
+	var calls atomic.Int32
+	factory := func(ctx context.Context) (*testResource, error) {
+		if calls.Add(1) == 1 {
+			return nil, errors.New("transient create error")
+		}
+		time.Sleep(5 * time.Millisecond)
+		return newTestResource(ctx)
+	}
+	p, err := New(factory, 0, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetMaxCreating(1)
+
+	var failed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Get(context.Background()); err != nil {
+				failed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := failed.Load(); n != 1 {
+		t.Fatalf("expected one failed Get, got %d", n)
+	}
+}
+
+func TestCreateBackoff(t *testing.T) {
+	var fail atomic.Bool
+	factory := func(ctx context.Context) (*testResource, error) {
+		if fail.Load() {
+			return nil, errors.New("connection refused")
+		}
+		return newTestResource(ctx)
+	}
+	p, err := New(factory, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetCreateBackoff(50*time.Millisecond, time.Second)
+
+	fail.Store(true)
+	if _, err := p.Get(context.Background()); err == nil {
+		t.Fatal("expected Get to fail while the factory does")
+	}
+	fail.Store(false)
+	start := time.Now()
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("expected a create after the backoff, got %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected Get to wait out the backoff, took %s", elapsed)
+	}
+	p.Put(r)
+}
@@ -0,0 +1,137 @@
+package pool
+
+import (
+	"fmt"
+	"time"
+)
+
+// EvictReason tells which rule removed a resource from the pool.
+type EvictReason int
+
+const (
+	// EvictMaxLifetime means the resource was older than its max lifetime,
+	// counted from its creation.
+	EvictMaxLifetime EvictReason = iota + 1
+	// EvictIdleTimeout means the resource sat unused for longer than its
+	// max idle time.
+	EvictIdleTimeout
+	// EvictLRU means the resource was the least recently used one while
+	// the pool held more idle resources than wanted.
+	EvictLRU
+	// EvictMaxUses means the resource was borrowed too many times.
+	EvictMaxUses
+	// EvictPolicy is for custom EvictionPolicy implementations.
+	EvictPolicy
+	// EvictPingFailed means a keepalive ping found the resource dead.
+	EvictPingFailed
+	// EvictCheckFailed means the resource failed the borrow check.
+	EvictCheckFailed
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictMaxLifetime:
+		return "max lifetime"
+	case EvictIdleTimeout:
+		return "idle timeout"
+	case EvictLRU:
+		return "lru"
+	case EvictMaxUses:
+		return "max uses"
+	case EvictPolicy:
+		return "policy"
+	case EvictPingFailed:
+		return "ping failed"
+	case EvictCheckFailed:
+		return "check failed"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictionInfo describes an idle resource the reaper is considering.
+type EvictionInfo struct {
+	Idle    int // idle resources in the pool, including this one
+	IdleFor time.Duration
+	Age     time.Duration
+	Uses    int
+}
+
+// EvictionPolicy decides which idle resources the reaper closes. The
+// reaper visits idle resources least recently used first. Evictions for
+// EvictIdleTimeout or EvictLRU are skipped while the pool is at min idle.
+type EvictionPolicy[T any] interface {
+	ShouldEvict(res T, info EvictionInfo) (EvictReason, bool)
+}
+
+// IdleTimePolicy evicts resources idle for longer than Timeout.
+type IdleTimePolicy[T any] struct {
+	Timeout time.Duration
+}
+
+func (ip IdleTimePolicy[T]) ShouldEvict(res T, info EvictionInfo) (EvictReason, bool) {
+	return EvictIdleTimeout, info.IdleFor > ip.Timeout
+}
+
+// LRUPolicy keeps at most MaxIdle idle resources, evicting the least
+// recently used ones first.
+type LRUPolicy[T any] struct {
+	MaxIdle int
+}
+
+func (lp LRUPolicy[T]) ShouldEvict(res T, info EvictionInfo) (EvictReason, bool) {
+	return EvictLRU, info.Idle > lp.MaxIdle
+}
+
+// MaxUsesPolicy evicts resources borrowed MaxUses times or more.
+type MaxUsesPolicy[T any] struct {
+	MaxUses int
+}
+
+func (mp MaxUsesPolicy[T]) ShouldEvict(res T, info EvictionInfo) (EvictReason, bool) {
+	return EvictMaxUses, info.Uses >= mp.MaxUses
+}
+
+// SetEvictionPolicy replaces the reaper's idle time check with policy.
+// Max lifetime and max uses are still enforced.
+func (p *Pool[T, ID]) SetEvictionPolicy(policy EvictionPolicy[T]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = policy
+}
+
+// SetMinIdle makes the reaper keep at least n idle resources, and the
+// refill worker create replacements when there are fewer.
+func (p *Pool[T, ID]) SetMinIdle(n int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n < 0 || n > cap(p.resources) {
+		return fmt.Errorf("invalid min idle %d", n)
+	}
+	p.minIdle = n
+	p.signalRefill()
+	return nil
+}
+
+// SetOnEvict registers fn to be called whenever a resource is evicted,
+// with the resource's ID and the rule it broke. It is called without the
+// pool's lock held.
+func (p *Pool[T, ID]) SetOnEvict(fn func(id ID, reason EvictReason)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onEvict = fn
+}
+
+// evict closes res, which the caller has taken out of circulation, counts
+// it under reason and reports it to the OnEvict callback.
+func (p *Pool[T, ID]) evict(res T, reason EvictReason) {
+	p.mu.Lock()
+	p.evictions[reason]++
+	p.destroy(res)
+	p.signalRefill()
+	onEvict := p.onEvict
+	p.mu.Unlock()
+	if onEvict != nil {
+		onEvict(res.GetID(), reason)
+	}
+}
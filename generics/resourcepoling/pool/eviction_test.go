@@ -0,0 +1,377 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dbConn is a fake database connection that can be told to fail pings,
+// hang on them, or fail to commit.
+type dbConn struct {
+	id         int
+	closed     bool
+	inTx       bool
+	dead       bool
+	hang       bool
+	failCommit bool
+}
+
+func (c *dbConn) GetID() int {
+	return c.id
+}
+
+func (c *dbConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *dbConn) Ping(ctx context.Context) error {
+	if c.dead {
+		return errors.New("connection reset by peer")
+	}
+	if c.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (c *dbConn) Begin(ctx context.Context) error {
+	if c.inTx {
+		return errors.New("already in a transaction")
+	}
+	c.inTx = true
+	return nil
+}
+
+func (c *dbConn) Commit() error {
+	if c.failCommit {
+		return errors.New("commit failed: connection lost")
+	}
+	if !c.inTx {
+		return errors.New("not in a transaction")
+	}
+	c.inTx = false
+	return nil
+}
+
+func (c *dbConn) Rollback() error {
+	if !c.inTx {
+		return errors.New("not in a transaction")
+	}
+	c.inTx = false
+	return nil
+}
+
+// dbConnFactory returns a factory numbering its connections from 1.
+func dbConnFactory() Factory[*dbConn] {
+	var mu sync.Mutex
+	n := 0
+	return func(ctx context.Context) (*dbConn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return &dbConn{id: n}, nil
+	}
+}
+
+func newDBPool(t *testing.T, initial, max int) *Pool[*dbConn, int] {
+	t.Helper()
+	p, err := New(dbConnFactory(), initial, max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+// idleSince backdates when res was last used, so the reaper sees it as
+// idle for d already.
+func idleSince(p *Pool[*dbConn, int], res *dbConn, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries[res.GetID()].lastUsed = time.Now().Add(-d)
+}
+
+func TestRecycleLimit(t *testing.T) {
+	p := newDBPool(t, 4, 4)
+	p.SetTargetIdle(0)
+	p.SetMaxLifetime(10 * time.Millisecond)
+	p.SetRecycleLimit(1, time.Hour)
+	time.Sleep(20 * time.Millisecond)
+
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetID() != 2 {
+		t.Fatalf("expected only resource 1 to be recycled, got resource %d", r.GetID())
+	}
+	if n := p.Len(); n != 2 {
+		t.Fatalf("expected 2 idle resources, got %d", n)
+	}
+}
+
+func TestExpiryJitter(t *testing.T) {
+	p := newDBPool(t, 1, 1)
+	p.SetExpiryJitter(10 * time.Second)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	distinct := map[time.Duration]bool{}
+	for id := 1; id <= 10; id++ {
+		j := p.jitterFor(id)
+		if j < 0 || j >= 10*time.Second {
+			t.Fatalf("jitter %s for resource %d out of range", j, id)
+		}
+		if j != p.jitterFor(id) {
+			t.Fatalf("jitter for resource %d is not stable", id)
+		}
+		distinct[j] = true
+	}
+	if len(distinct) < 2 {
+		t.Fatal("expected jitter to differ between resources")
+	}
+}
+
+func TestEvictReasons(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	reasons := map[EvictReason]int{}
+	onEvict := func(id int, reason EvictReason) {
+		mu.Lock()
+		reasons[reason]++
+		mu.Unlock()
+	}
+
+	busy := newDBPool(t, 1, 1)
+	busy.SetMaxLifetime(50 * time.Millisecond)
+	busy.SetOnEvict(onEvict)
+	for i := 0; i < 10; i++ {
+		r, err := busy.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		busy.Put(r)
+	}
+
+	idle := newDBPool(t, 1, 1)
+	idle.SetMaxIdleTime(20 * time.Millisecond)
+	idle.SetOnEvict(onEvict)
+	time.Sleep(30 * time.Millisecond)
+	r, err := idle.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetID() == 1 {
+		t.Fatal("expected idle resource 1 to be evicted")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if reasons[EvictMaxLifetime] == 0 {
+		t.Fatal("expected a resource in constant use to hit max lifetime")
+	}
+	if reasons[EvictIdleTimeout] != 1 {
+		t.Fatalf("expected one idle timeout eviction, got %d", reasons[EvictIdleTimeout])
+	}
+	if s := busy.Stats(); s.MaxLifetimeClosed != int64(reasons[EvictMaxLifetime]) {
+		t.Fatalf("expected stats to count every max lifetime eviction, got %+v", s)
+	}
+}
+
+func TestReaperKeepsMinIdle(t *testing.T) {
+	p := newDBPool(t, 3, 3)
+	p.SetTargetIdle(0)
+	if err := p.SetMinIdle(1); err != nil {
+		t.Fatal(err)
+	}
+	p.SetMaxIdleTime(10 * time.Millisecond)
+	p.SetReapInterval(20 * time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if n := p.Len(); n != 1 {
+		t.Fatalf("expected the reaper to keep 1 idle resource, got %d", n)
+	}
+	if err := p.SetMinIdle(4); err == nil {
+		t.Fatal("expected min idle above max to be rejected")
+	}
+}
+
+func TestReaperPolicies(t *testing.T) {
+	lru := newDBPool(t, 3, 3)
+	lru.SetTargetIdle(0)
+	var mu sync.Mutex
+	var evicted []int
+	lru.SetOnEvict(func(id int, reason EvictReason) {
+		mu.Lock()
+		evicted = append(evicted, id)
+		mu.Unlock()
+	})
+	lru.SetEvictionPolicy(LRUPolicy[*dbConn]{MaxIdle: 2})
+	lru.SetReapInterval(20 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if n := lru.Len(); n != 2 {
+		t.Fatalf("expected the LRU policy to keep 2 idle resources, got %d", n)
+	}
+	mu.Lock()
+	if len(evicted) != 1 || evicted[0] != 1 {
+		t.Fatalf("expected least recently used resource 1 to be evicted, got %v", evicted)
+	}
+	mu.Unlock()
+
+	uses := newDBPool(t, 1, 1)
+	uses.SetEvictionPolicy(MaxUsesPolicy[*dbConn]{MaxUses: 2})
+	uses.SetReapInterval(20 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		r, err := uses.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		uses.Put(r)
+	}
+	time.Sleep(50 * time.Millisecond)
+	r, err := uses.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetID() == 1 {
+		t.Fatal("expected resource 1 to be replaced after 2 uses")
+	}
+	if s := uses.Stats(); s.MaxUsesClosed != 1 {
+		t.Fatalf("expected one max uses eviction, got %+v", s)
+	}
+}
+
+func TestKeepalivePing(t *testing.T) {
+	p := newDBPool(t, 2, 2)
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.dead = true
+	p.Put(r)
+	idleSince(p, r, time.Minute)
+
+	var mu sync.Mutex
+	var evicted int
+	p.SetOnEvict(func(id int, reason EvictReason) {
+		mu.Lock()
+		defer mu.Unlock()
+		if reason == EvictPingFailed {
+			evicted = id
+		}
+	})
+	p.SetKeepalive(10*time.Millisecond, 10*time.Millisecond, true)
+	time.Sleep(60 * time.Millisecond)
+
+	mu.Lock()
+	if evicted != r.GetID() {
+		t.Fatalf("expected dead resource %d to be evicted, got %d", r.GetID(), evicted)
+	}
+	mu.Unlock()
+	for i := 0; p.Len() != 2; i++ {
+		if i == 50 {
+			t.Fatalf("expected the dead resource to be replaced, got %d idle", p.Len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestKeepaliveShorterThanReapInterval(t *testing.T) {
+	p := newDBPool(t, 1, 1)
+	p.SetReapInterval(time.Hour)
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.dead = true
+	p.Put(r)
+	idleSince(p, r, time.Minute)
+
+	p.SetKeepalive(10*time.Millisecond, 10*time.Millisecond, false)
+	for i := 0; p.Stats().HealthCheckFailed != 1; i++ {
+		if i == 50 {
+			t.Fatalf("expected the keepalive to ping ahead of the hour-long reap interval, got %+v", p.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestKeepaliveHungPingTimesOut(t *testing.T) {
+	p := newDBPool(t, 1, 1)
+	p.SetKeepalive(time.Millisecond, 0, false)
+	p.mu.Lock()
+	timeout := p.keepalive.timeout
+	p.mu.Unlock()
+	if timeout != defaultPingTimeout {
+		t.Fatalf("expected a zero timeout to default to %s, got %s", defaultPingTimeout, timeout)
+	}
+
+	evicted := make(chan EvictReason, 1)
+	p.SetOnEvict(func(id int, reason EvictReason) {
+		select {
+		case evicted <- reason:
+		default:
+		}
+	})
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.hang = true
+	p.SetKeepalive(time.Millisecond, 20*time.Millisecond, false)
+	p.Put(r)
+
+	select {
+	case reason := <-evicted:
+		if reason != EvictPingFailed {
+			t.Fatalf("expected EvictPingFailed, got %s", reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the hung ping to time out and evict the resource")
+	}
+}
+
+type hostPort struct {
+	Host string
+	Port int
+}
+
+type endpointConn struct {
+	addr hostPort
+}
+
+func (c *endpointConn) Close() error    { return nil }
+func (c *endpointConn) GetID() hostPort { return c.addr }
+
+func TestCompositeID(t *testing.T) {
+	port := 5432
+	factory := func(ctx context.Context) (*endpointConn, error) {
+		port++
+		return &endpointConn{addr: hostPort{"10.0.0.1", port}}, nil
+	}
+	p, err := New(factory, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := hostPort{"10.0.0.1", 5433}
+	if r.GetID() != want {
+		t.Fatalf("expected %v, got %v", want, r.GetID())
+	}
+	p.mu.Lock()
+	uses := p.entries[want].uses
+	p.mu.Unlock()
+	if uses != 1 {
+		t.Fatalf("expected 1 use recorded for %v, got %d", want, uses)
+	}
+}
@@ -0,0 +1,82 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type healthCheck struct {
+	timeout    time.Duration
+	skipRecent time.Duration
+	background bool
+}
+
+// SetHealthCheck is SetBorrowCheck for checks that take a context, such as
+// a database ping. On borrow ctx is the Get's, so the check gives up when
+// the Get does.
+func (p *Pool[T, ID]) SetHealthCheck(check func(ctx context.Context, res T) error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.check = check
+}
+
+// SetHealthCheckTimeout bounds each health check to timeout. A check that
+// doesn't return in time counts as failed, even if it ignores its context.
+func (p *Pool[T, ID]) SetHealthCheckTimeout(timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.health.timeout = timeout
+}
+
+// SetHealthCheckSkipRecent skips the borrow-time health check for
+// resources returned to the pool less than d ago.
+func (p *Pool[T, ID]) SetHealthCheckSkipRecent(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.health.skipRecent = d
+}
+
+// SetBackgroundHealthCheck moves health checks off the borrow path. Idle
+// resources are only checked by the reaper, which is started at interval
+// if it is not running. Zero moves checks back to the borrow path.
+func (p *Pool[T, ID]) SetBackgroundHealthCheck(interval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.health.background = interval > 0
+	if p.reapInterval <= 0 {
+		p.startReaper(interval)
+	}
+}
+
+// checkOnBorrow reports whether Get should run the health check on the
+// resource behind e before lending it. It must be called with p.mu held.
+func (p *Pool[T, ID]) checkOnBorrow(e *entry, now time.Time) bool {
+	if p.health.background {
+		return false
+	}
+	return p.health.skipRecent <= 0 || now.Sub(e.lastUsed) >= p.health.skipRecent
+}
+
+// runCheck runs check on res, giving up once ctx is done or the health
+// check timeout passes, even if the check itself hangs.
+func (p *Pool[T, ID]) runCheck(ctx context.Context, check func(context.Context, T) error, res T) error {
+	p.mu.Lock()
+	timeout := p.health.timeout
+	p.mu.Unlock()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx, res)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("health check: %w", ctx.Err())
+	}
+}
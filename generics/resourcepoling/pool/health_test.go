@@ -0,0 +1,104 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHungHealthCheckTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var hang atomic.Bool
+	hang.Store(true)
+	p := newDBPool(t, 1, 1)
+	p.SetHealthCheck(func(context.Context, *dbConn) error {
+		if hang.Swap(false) {
+			<-release
+		}
+		return nil
+	})
+	p.SetHealthCheckTimeout(20 * time.Millisecond)
+
+	start := time.Now()
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Get waited %s on a hung health check", elapsed)
+	}
+	if r.GetID() != 2 {
+		t.Fatalf("expected a replacement resource, got %d", r.GetID())
+	}
+	if s := p.Stats(); s.HealthCheckFailed != 1 {
+		t.Fatalf("expected one failed check, got %+v", s)
+	}
+}
+
+func TestHealthCheckGivesUpWithGet(t *testing.T) {
+	p := newDBPool(t, 1, 1)
+	p.SetHealthCheck(func(ctx context.Context, c *dbConn) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the Get's deadline, got %v", err)
+	}
+	if s := p.Stats(); s.HealthCheckFailed != 0 || s.Idle != 1 {
+		t.Fatalf("expected the resource back in the pool unharmed, got %+v", s)
+	}
+}
+
+func TestHealthCheckSkipRecent(t *testing.T) {
+	var checks atomic.Int32
+	p := newDBPool(t, 1, 1)
+	p.SetHealthCheck(func(context.Context, *dbConn) error {
+		checks.Add(1)
+		return nil
+	})
+	p.SetHealthCheckSkipRecent(50 * time.Millisecond)
+
+	r, _ := p.Get(context.Background())
+	p.Put(r)
+	r, _ = p.Get(context.Background())
+	if n := checks.Load(); n != 0 {
+		t.Fatalf("expected a recently used resource to skip the check, got %d checks", n)
+	}
+	p.Put(r)
+	time.Sleep(60 * time.Millisecond)
+	r, _ = p.Get(context.Background())
+	p.Put(r)
+	if n := checks.Load(); n != 1 {
+		t.Fatalf("expected 1 check once the resource sat idle, got %d", n)
+	}
+}
+
+func TestBackgroundHealthCheck(t *testing.T) {
+	var checks atomic.Int32
+	p := newDBPool(t, 2, 2)
+	p.SetTargetIdle(0)
+	p.SetHealthCheck(func(context.Context, *dbConn) error {
+		checks.Add(1)
+		return errors.New("broken")
+	})
+	p.SetBackgroundHealthCheck(100 * time.Millisecond)
+
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(r)
+	if n := checks.Load(); n != 0 {
+		t.Fatalf("expected no check on borrow in background mode, got %d", n)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if s := p.Stats(); s.HealthCheckFailed != 2 || s.Idle != 0 || s.OpenConnections != 0 {
+		t.Fatalf("expected both idle resources closed by the reaper, got %+v", s)
+	}
+}
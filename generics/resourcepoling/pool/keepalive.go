@@ -0,0 +1,73 @@
+package pool
+
+import (
+	"context"
+	"time"
+)
+
+// Pinger is implemented by resources that can check their connection is
+// still alive, such as a database session behind a firewall that drops
+// idle TCP connections.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// defaultPingTimeout bounds keepalive pings when SetKeepalive is given no
+// timeout, so a hung ping cannot stall the reaper.
+const defaultPingTimeout = 5 * time.Second
+
+type keepalive struct {
+	interval time.Duration
+	timeout  time.Duration
+	replace  bool
+}
+
+// SetKeepalive makes the reaper ping idle resources implementing Pinger
+// once they have gone interval without being used or pinged, starting the
+// reaper at interval if it is not running. A running reaper with a longer
+// reap interval wakes every interval instead, so pings stay on time. Each ping is bounded by
+// timeout, or defaultPingTimeout if timeout is not positive. Resources
+// failing a ping are closed and, if replace is set, a new resource is
+// created in their place.
+func (p *Pool[T, ID]) SetKeepalive(interval, timeout time.Duration, replace bool) {
+	if timeout <= 0 {
+		timeout = defaultPingTimeout
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keepalive = keepalive{interval: interval, timeout: timeout, replace: replace}
+	if p.reapInterval <= 0 {
+		p.startReaper(interval)
+		return
+	}
+	p.resetReaper()
+}
+
+// ping pings res if it is due. It is called by the reaper with res taken
+// off the idle channel, so no lock is held while waiting for the resource.
+func (p *Pool[T, ID]) ping(res T) error {
+	pinger, ok := any(res).(Pinger)
+	if !ok {
+		return nil
+	}
+	p.mu.Lock()
+	ka := p.keepalive
+	e := p.entries[res.GetID()]
+	last := e.lastUsed
+	if e.lastPing.After(last) {
+		last = e.lastPing
+	}
+	p.mu.Unlock()
+	if ka.interval <= 0 || time.Since(last) < ka.interval {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ka.timeout)
+	defer cancel()
+	if err := pinger.Ping(ctx); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	e.lastPing = time.Now()
+	p.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,262 @@
+package pool
+
+import (
+	"context"
+	"hash/maphash"
+	"time"
+)
+
+// SetMaxLifetime closes resources once they are older than d, when they
+// are next borrowed, returned or reaped. Zero keeps them forever.
+func (p *Pool[T, ID]) SetMaxLifetime(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxLifetime = d
+}
+
+// SetMaxIdleTime closes resources that have sat idle for longer than d.
+// Zero keeps idle resources forever.
+func (p *Pool[T, ID]) SetMaxIdleTime(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxIdleTime = d
+}
+
+// SetMaxUses closes resources after they have been borrowed n times. Zero
+// means no limit.
+func (p *Pool[T, ID]) SetMaxUses(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxUses = n
+}
+
+// SetExpiryJitter shortens the max lifetime and idle time of each resource
+// by a random amount up to jitter, fixed per resource ID, so resources
+// created together do not all expire together.
+func (p *Pool[T, ID]) SetExpiryJitter(jitter time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expiryJitter = jitter
+}
+
+// SetRecycleLimit allows at most n resources to expire per interval.
+// Resources past their limit stay in use until they are allowed.
+func (p *Pool[T, ID]) SetRecycleLimit(n int, interval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recycle = recycleLimiter{max: n, interval: interval}
+}
+
+// SetReapInterval starts a background reaper that, every interval, closes
+// idle resources that have expired, are evicted by the eviction policy,
+// fail the borrow check or a keepalive ping, then tops the pool back up
+// to its target idle level. Zero stops the reaper.
+func (p *Pool[T, ID]) SetReapInterval(interval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.startReaper(interval)
+}
+
+// startReaper sets the reap interval, starting the reaper if it is not
+// running or waking it to use the new interval if it is. It must be called
+// with p.mu held.
+func (p *Pool[T, ID]) startReaper(interval time.Duration) {
+	start := p.reapInterval <= 0 && interval > 0
+	p.reapInterval = interval
+	if start && !p.closed {
+		go p.reaper()
+		return
+	}
+	p.resetReaper()
+}
+
+// resetReaper makes a running reaper start its wait over with the current
+// tick. It must be called with p.mu held.
+func (p *Pool[T, ID]) resetReaper() {
+	select {
+	case p.reapReset <- struct{}{}:
+	default:
+	}
+}
+
+// reapTick is how long the reaper waits between passes: the reap interval,
+// or the keepalive interval if that is shorter, so pings are not held back
+// by a long reap interval. It must be called with p.mu held.
+func (p *Pool[T, ID]) reapTick() time.Duration {
+	tick := p.reapInterval
+	if ka := p.keepalive.interval; ka > 0 && ka < tick {
+		tick = ka
+	}
+	return tick
+}
+
+// recycleLimiter allows at most max expiries per interval so resources
+// created together are not all recycled in the same instant.
+type recycleLimiter struct {
+	max         int
+	interval    time.Duration
+	windowStart time.Time
+	count       int
+}
+
+func (l *recycleLimiter) allow(now time.Time) bool {
+	if l.max <= 0 {
+		return true
+	}
+	if now.Sub(l.windowStart) >= l.interval {
+		l.windowStart = now
+		l.count = 0
+	}
+	if l.count >= l.max {
+		return false
+	}
+	l.count++
+	return true
+}
+
+// jitterFor returns how much earlier than the configured limits the
+// resource with id expires. It must be called with p.mu held.
+func (p *Pool[T, ID]) jitterFor(id ID) time.Duration {
+	if p.expiryJitter <= 0 {
+		return 0
+	}
+	h := maphash.Comparable(p.jitterSeed, id)
+	return time.Duration(h % uint64(p.expiryJitter))
+}
+
+// expired reports whether the resource with id has broken rule,
+// EvictMaxLifetime or EvictIdleTimeout, after applying its jitter, and
+// whether the recycle limit lets it go now. It must be called with p.mu
+// held.
+func (p *Pool[T, ID]) expired(id ID, e *entry, rule EvictReason, now time.Time) bool {
+	t, limit := e.lastUsed, p.maxIdleTime
+	if rule == EvictMaxLifetime {
+		t, limit = e.createdAt, p.maxLifetime
+	}
+	if limit <= 0 || now.Sub(t) < limit-p.jitterFor(id) {
+		return false
+	}
+	return p.recycle.allow(now)
+}
+
+// checkExpiry reports which rule the resource with id has broken, if any.
+// Age is measured from creation and idle time from the last Put,
+// independently. It must be called with p.mu held.
+func (p *Pool[T, ID]) checkExpiry(id ID, e *entry, now time.Time) (EvictReason, bool) {
+	if p.expired(id, e, EvictMaxLifetime, now) {
+		return EvictMaxLifetime, true
+	}
+	if p.expired(id, e, EvictIdleTimeout, now) {
+		return EvictIdleTimeout, true
+	}
+	return 0, false
+}
+
+func (p *Pool[T, ID]) reaper() {
+	for {
+		p.mu.Lock()
+		interval := p.reapTick()
+		p.mu.Unlock()
+		if interval <= 0 {
+			return
+		}
+		select {
+		case <-p.done:
+			return
+		case <-p.reapReset:
+			continue
+		case <-time.After(interval):
+		}
+		p.reap()
+		p.signalRefill()
+	}
+}
+
+// reap checks every resource idle at the start of the pass, least recently
+// used first. Resources are taken off the channel one at a time, so Gets
+// can keep taking the rest meanwhile. The refill worker waits for the pass
+// to end, since the resource being checked is missing from the idle count.
+func (p *Pool[T, ID]) reap() {
+	p.mu.Lock()
+	p.reaping = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.reaping = false
+		p.mu.Unlock()
+	}()
+	n := len(p.resources)
+	for i := 0; i < n; i++ {
+		var res T
+		select {
+		case r, ok := <-p.resources:
+			if !ok {
+				return
+			}
+			res = r
+		default:
+			return
+		}
+		if reason, ok := p.reapReason(res, len(p.resources)+1); ok {
+			p.evict(res, reason)
+			continue
+		}
+		p.mu.Lock()
+		check := p.check
+		p.mu.Unlock()
+		if check != nil && p.runCheck(context.Background(), check, res) != nil {
+			p.evict(res, EvictCheckFailed)
+			continue
+		}
+		if err := p.ping(res); err != nil {
+			p.logger.Printf("resource %v failed keepalive ping: %s\n", res.GetID(), err)
+			p.evict(res, EvictPingFailed)
+			p.mu.Lock()
+			replace := p.keepalive.replace
+			p.mu.Unlock()
+			if replace {
+				p.replace()
+			}
+			continue
+		}
+		p.putIdle(res)
+	}
+}
+
+// reapReason decides whether the reaper evicts res, one of idle idle
+// resources. Max lifetime and max uses always apply. The idle time check,
+// or the eviction policy if one is set, leaves at least minIdle resources.
+func (p *Pool[T, ID]) reapReason(res T, idle int) (EvictReason, bool) {
+	now := time.Now()
+	p.mu.Lock()
+	e := p.entries[res.GetID()]
+	if p.expired(res.GetID(), e, EvictMaxLifetime, now) {
+		p.mu.Unlock()
+		return EvictMaxLifetime, true
+	}
+	if p.maxUses > 0 && e.uses >= p.maxUses {
+		p.mu.Unlock()
+		return EvictMaxUses, true
+	}
+	policy := p.policy
+	minIdle := p.minIdle
+	if policy == nil {
+		defer p.mu.Unlock()
+		if idle <= minIdle {
+			return 0, false
+		}
+		return EvictIdleTimeout, p.expired(res.GetID(), e, EvictIdleTimeout, now)
+	}
+	info := EvictionInfo{
+		Idle:    idle,
+		IdleFor: now.Sub(e.lastUsed),
+		Age:     now.Sub(e.createdAt),
+		Uses:    e.uses,
+	}
+	p.mu.Unlock()
+	reason, ok := policy.ShouldEvict(res, info)
+	if !ok || (idle <= minIdle && (reason == EvictIdleTimeout || reason == EvictLRU)) {
+		return 0, false
+	}
+	return reason, true
+}
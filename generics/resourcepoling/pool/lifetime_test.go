@@ -0,0 +1,127 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestPool(t *testing.T, initial, max int) *Pool[*testResource, int] {
+	t.Helper()
+	p, err := New(newTestResource, initial, max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestAcquireTimeout(t *testing.T) {
+	p := newTestPool(t, 1, 1)
+	p.SetAcquireTimeout(20 * time.Millisecond)
+
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Put(r)
+	_, err = p.Get(context.Background())
+	if !errors.Is(err, ErrAcquireTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ErrAcquireTimeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Get(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the caller's cancellation, got %v", err)
+	}
+}
+
+func TestMaxLifetime(t *testing.T) {
+	p := newTestPool(t, 1, 1)
+	p.SetMaxLifetime(20 * time.Millisecond)
+
+	old, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	p.Put(old)
+	if !old.closed {
+		t.Fatal("expected Put to close a resource past its lifetime")
+	}
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == old {
+		t.Fatal("expected a fresh resource")
+	}
+	p.Put(r)
+	if s := p.Stats(); s.MaxLifetimeClosed != 1 || s.OpenConnections != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestMaxUses(t *testing.T) {
+	p := newTestPool(t, 0, 1)
+	p.SetMaxUses(2)
+
+	var first *testResource
+	for i := 0; i < 3; i++ {
+		r, err := p.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first = r
+		}
+		if i == 2 && r == first {
+			t.Fatal("expected the resource to be replaced after 2 uses")
+		}
+		p.Put(r)
+	}
+	if !first.closed {
+		t.Fatal("expected the worn out resource to be closed")
+	}
+	if s := p.Stats(); s.MaxUsesClosed != 1 {
+		t.Fatalf("expected one resource closed for max uses, got %+v", s)
+	}
+}
+
+func TestReaper(t *testing.T) {
+	p := newTestPool(t, 2, 4)
+	p.SetMaxIdleTime(20 * time.Millisecond)
+
+	r, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(r)
+	p.SetBorrowCheck(func(res *testResource) error {
+		if res == r {
+			return errors.New("unhealthy")
+		}
+		return nil
+	})
+	p.SetReapInterval(10 * time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	s := p.Stats()
+	for s.HealthCheckFailed == 0 || s.MaxIdleClosed == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the reaper to close unhealthy and idle resources, got %+v", s)
+		}
+		time.Sleep(5 * time.Millisecond)
+		s = p.Stats()
+	}
+	p.SetReapInterval(0)
+	time.Sleep(20 * time.Millisecond)
+	for s = p.Stats(); s.Idle != 2 || s.OpenConnections != 2; s = p.Stats() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the pool to be topped back up to 2 idle, got %+v", s)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
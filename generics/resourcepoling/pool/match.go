@@ -0,0 +1,191 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoMatch is returned by GetMatching when no idle resource matches and
+// the factory cannot create one that does.
+var ErrNoMatch = errors.New("pool: no resource matches")
+
+// Tagged is implemented by resources that carry metadata such as the
+// replica they are connected to.
+type Tagged interface {
+	GetTags() map[string]string
+}
+
+// HasTags returns a predicate for GetMatching that accepts Tagged
+// resources carrying every key/value pair in want.
+func HasTags[T any](want map[string]string) func(T) bool {
+	return func(res T) bool {
+		tagged, ok := any(res).(Tagged)
+		if !ok {
+			return false
+		}
+		tags := tagged.GetTags()
+		for k, v := range want {
+			if tags[k] != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SetMatchingFactory registers fn to create resources for GetMatching. It
+// should return a resource satisfying match, or ErrNoMatch. Without it,
+// GetMatching calls the pool's factory and checks the result.
+func (p *Pool[T, ID]) SetMatchingFactory(fn func(ctx context.Context, match func(T) bool) (T, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.matchFactory = fn
+}
+
+// GetMatching returns an idle resource satisfying match. If none is idle it
+// creates one, through the matching factory when one is set. When the pool
+// is at max, a non-matching idle resource is closed to make room.
+func (p *Pool[T, ID]) GetMatching(ctx context.Context, match func(T) bool) (T, error) {
+	ctx, cancel := p.acquireContext(ctx)
+	defer cancel()
+	res, err := p.getMatching(ctx, match)
+	if err != nil {
+		return res, err
+	}
+	if res, err = p.acquireWeight(ctx, res); err != nil {
+		return res, err
+	}
+	p.borrow(ctx, res)
+	return res, nil
+}
+
+func (p *Pool[T, ID]) getMatching(ctx context.Context, match func(T) bool) (T, error) {
+	var zero T
+	for {
+		if ctx.Err() != nil {
+			return zero, context.Cause(ctx)
+		}
+		res, found, evicted := p.scanIdle(match)
+		if found {
+			if ok, err := p.take(ctx, res); ok || err != nil {
+				return res, err
+			}
+			continue
+		}
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return zero, ErrPoolClosed
+		}
+		if evicted || p.open < p.max {
+			if !evicted {
+				p.open++
+			}
+			p.mu.Unlock()
+			return p.createMatching(ctx, match)
+		}
+		freed := p.freed
+		p.mu.Unlock()
+
+		select {
+		case res, ok := <-p.resources:
+			if !ok {
+				return zero, ErrPoolClosed
+			}
+			if match(res) {
+				if ok, err := p.take(ctx, res); ok || err != nil {
+					return res, err
+				}
+				continue
+			}
+			p.mu.Lock()
+			p.closeKeepSlot(res)
+			p.mu.Unlock()
+			return p.createMatching(ctx, match)
+		case <-freed:
+		case <-ctx.Done():
+			return zero, context.Cause(ctx)
+		}
+	}
+}
+
+// scanIdle takes every idle resource off the channel, keeps the first one
+// satisfying match and returns the rest. If nothing matches and the pool is
+// at max, one non-matching resource is closed and evicted is true; its
+// slot in open is then owned by the caller.
+func (p *Pool[T, ID]) scanIdle(match func(T) bool) (res T, found, evicted bool) {
+	var idle []T
+	n := len(p.resources)
+scan:
+	for i := 0; i < n; i++ {
+		select {
+		case r, ok := <-p.resources:
+			if !ok {
+				break scan
+			}
+			if !found && match(r) {
+				res, found = r, true
+				continue
+			}
+			idle = append(idle, r)
+		default:
+			break scan
+		}
+	}
+	p.mu.Lock()
+	if !found && len(idle) > 0 && p.open >= p.max && !p.closed {
+		p.closeKeepSlot(idle[0])
+		idle = idle[1:]
+		evicted = true
+	}
+	p.mu.Unlock()
+	for _, r := range idle {
+		p.putIdle(r)
+	}
+	return res, found, evicted
+}
+
+// closeKeepSlot closes an idle resource whose slot in open is handed to
+// the caller instead of being given up. It must be called with p.mu held.
+func (p *Pool[T, ID]) closeKeepSlot(res T) {
+	delete(p.entries, res.GetID())
+	res.Close()
+}
+
+// createMatching creates a resource for an already reserved slot in open,
+// giving up the slot if creation fails.
+func (p *Pool[T, ID]) createMatching(ctx context.Context, match func(T) bool) (T, error) {
+	var zero T
+	p.mu.Lock()
+	mf := p.matchFactory
+	p.mu.Unlock()
+	var res T
+	var err error
+	if mf != nil {
+		res, err = mf(ctx, match)
+	} else {
+		res, err = p.factory(ctx)
+		if err == nil && !match(res) {
+			res.Close()
+			err = ErrNoMatch
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.release()
+		return zero, fmt.Errorf("cannot create resource: %w", err)
+	}
+	if p.closed {
+		p.release()
+		res.Close()
+		return zero, ErrPoolClosed
+	}
+	e := p.track(res)
+	e.uses++
+	e.borrowedAt = time.Now()
+	p.inUse++
+	return res, nil
+}
@@ -0,0 +1,87 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type taggedResource struct {
+	testResource
+	tags map[string]string
+}
+
+func (r *taggedResource) GetTags() map[string]string {
+	return r.tags
+}
+
+func TestGetMatching(t *testing.T) {
+	tagSets := []map[string]string{
+		{"role": "primary"},
+		{"role": "replica"},
+	}
+	newTagged := func(ctx context.Context) (*taggedResource, error) {
+		r, _ := newTestResource(ctx)
+		return &taggedResource{testResource: *r}, nil
+	}
+	p, err := New(newTagged, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetMatchingFactory(func(ctx context.Context, match func(*taggedResource) bool) (*taggedResource, error) {
+		for _, tags := range tagSets {
+			if !match(&taggedResource{tags: tags}) {
+				continue
+			}
+			r, err := newTagged(ctx)
+			if err != nil {
+				return nil, err
+			}
+			r.tags = tags
+			return r, nil
+		}
+		return nil, ErrNoMatch
+	})
+	ctx := context.Background()
+	replica := HasTags[*taggedResource](map[string]string{"role": "replica"})
+	primary := HasTags[*taggedResource](map[string]string{"role": "primary"})
+
+	r1, err := p.GetMatching(ctx, replica)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r1.tags["role"] != "replica" {
+		t.Fatalf("expected a replica, got %v", r1.tags)
+	}
+	p.Put(r1)
+
+	r2, err := p.GetMatching(ctx, primary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r2.tags["role"] != "primary" {
+		t.Fatalf("expected a primary, got %v", r2.tags)
+	}
+
+	r3, err := p.GetMatching(ctx, replica)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r3 != r1 {
+		t.Fatalf("expected the idle replica %d to be reused, got %d", r1.GetID(), r3.GetID())
+	}
+	p.Put(r2)
+
+	_, err = p.GetMatching(ctx, HasTags[*taggedResource](map[string]string{"role": "analytics"}))
+	if !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch, got %v", err)
+	}
+	if !r2.closed {
+		t.Fatal("expected the idle primary to be closed to make room")
+	}
+	p.Put(r3)
+	if s := p.Stats(); s.OpenConnections != 1 || s.Idle != 1 {
+		t.Fatalf("expected the failed create to give up its slot, got %+v", s)
+	}
+}
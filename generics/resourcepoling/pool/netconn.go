@@ -0,0 +1,62 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// ErrConnClosedByPeer is returned by ProbeConn when the other end has hung
+// up, or has sent data nobody asked for.
+var ErrConnClosedByPeer = errors.New("connection closed by peer")
+
+// Conn is a pooled network connection. The pool tracks it by ID.
+type Conn struct {
+	net.Conn
+	id uint64
+}
+
+// GetID returns the connection's pool ID.
+func (c *Conn) GetID() uint64 {
+	return c.id
+}
+
+var connIDs atomic.Uint64
+
+// DialFactory returns a Factory that dials address with a net.Dialer using
+// the given connect timeout and TCP keepalive period. A zero keepAlive uses
+// the net package default, a negative one disables keepalives.
+func DialFactory(network, address string, timeout, keepAlive time.Duration) Factory[*Conn] {
+	d := net.Dialer{Timeout: timeout, KeepAlive: keepAlive}
+	return func(ctx context.Context) (*Conn, error) {
+		c, err := d.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &Conn{Conn: c, id: connIDs.Add(1)}, nil
+	}
+}
+
+// NewConnPool creates a pool of connections to address that probes every
+// connection with ProbeConn before lending it out.
+func NewConnPool(network, address string, timeout, keepAlive time.Duration, initial, max int) (*Pool[*Conn, uint64], error) {
+	p, err := New(DialFactory(network, address, timeout, keepAlive), initial, max)
+	if err != nil {
+		return nil, err
+	}
+	p.SetBorrowCheck(func(c *Conn) error { return ProbeConn(c) })
+	return p, nil
+}
+
+// ProbeConn checks an idle connection is still open without blocking. An
+// idle request/response connection should have nothing to read, so EOF,
+// a reset or pending data all mean it must not be reused. A *Conn is
+// probed through the connection it wraps, which exposes the socket.
+func ProbeConn(c net.Conn) error {
+	if pc, ok := c.(*Conn); ok {
+		c = pc.Conn
+	}
+	return probe(c)
+}
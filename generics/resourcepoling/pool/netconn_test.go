@@ -0,0 +1,219 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// echoServer accepts connections on a local port and echoes what they
+// send. hangUp closes every connection accepted so far.
+type echoServer struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newEchoServer(t *testing.T) *echoServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &echoServer{ln: ln}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, c)
+			s.mu.Unlock()
+			go io.Copy(c, c)
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		s.hangUp()
+	})
+	return s
+}
+
+func (s *echoServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *echoServer) accepted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+func (s *echoServer) hangUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func echo(t *testing.T, c net.Conn) {
+	t.Helper()
+	c.SetDeadline(time.Now().Add(time.Second))
+	defer c.SetDeadline(time.Time{})
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("expected ping echoed back, got %q", buf)
+	}
+}
+
+func TestDialFactory(t *testing.T) {
+	s := newEchoServer(t)
+	c, err := DialFactory("tcp", s.addr(), time.Second, 30*time.Second)(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	echo(t, c)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	if _, err := DialFactory("tcp", addr, time.Second, 0)(context.Background()); err == nil {
+		t.Fatal("expected dialing a closed port to fail")
+	}
+}
+
+func TestProbeConn(t *testing.T) {
+	for name, probe := range map[string]func(net.Conn) error{
+		"ProbeConn":     ProbeConn,
+		"probeDeadline": probeDeadline,
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newEchoServer(t)
+			c, err := net.Dial("tcp", s.addr())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if err := probe(c); err != nil {
+				t.Fatalf("expected a healthy connection, got %s", err)
+			}
+			echo(t, c)
+
+			if _, err := c.Write([]byte("x")); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if err := probe(c); err == nil {
+				t.Fatal("expected pending data to fail the probe")
+			}
+
+			c2, err := net.Dial("tcp", s.addr())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c2.Close()
+			for s.accepted() < 2 {
+				time.Sleep(time.Millisecond)
+			}
+			s.hangUp()
+			time.Sleep(20 * time.Millisecond)
+			if err := probe(c2); err == nil {
+				t.Fatal("expected a connection closed by the peer to fail the probe")
+			}
+		})
+	}
+}
+
+func TestConnPoolReplacesClosedConns(t *testing.T) {
+	s := newEchoServer(t)
+	p, err := NewConnPool("tcp", s.addr(), time.Second, 0, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for s.accepted() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	s.hangUp()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo(t, c)
+	p.Put(c)
+}
+
+func TestBorrowCheckFailureClosesResource(t *testing.T) {
+	p, err := New(newTestResource, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	checked := 0
+	p.SetBorrowCheck(func(r *testResource) error {
+		checked++
+		if checked == 1 {
+			return errors.New("stale")
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	r, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.closed {
+		t.Fatal("expected a fresh resource")
+	}
+	if checked != 1 {
+		t.Fatalf("expected the replacement to be created without a check, got %d checks", checked)
+	}
+	if s := p.Stats(); s.OpenConnections != 1 || s.InUse != 1 {
+		t.Fatalf("expected the failed resource to free its slot, got %+v", s)
+	}
+}
+
+type testResource struct {
+	id     int
+	closed bool
+}
+
+var testIDs atomic.Int64
+
+func newTestResource(ctx context.Context) (*testResource, error) {
+	return &testResource{id: int(testIDs.Add(1))}, nil
+}
+
+func (r *testResource) GetID() int {
+	return r.id
+}
+
+func (r *testResource) Close() error {
+	r.closed = true
+	return nil
+}
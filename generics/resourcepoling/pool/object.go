@@ -0,0 +1,179 @@
+package pool
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+)
+
+// Resetter is a value that can be cleared for reuse, such as a buffer,
+// encoder or parser. Unlike the resources in Pool it has nothing to close.
+type Resetter interface {
+	Reset()
+}
+
+// ObjectStats counts how an ObjectPool or SizedPool has been used.
+type ObjectStats struct {
+	Hits     int64 // Gets served from a retained object
+	Misses   int64 // Gets that had to allocate
+	Dropped  int64 // Puts discarded as over the retained limit or size
+	Retained int   // objects currently held
+}
+
+// ObjectPool keeps up to maxRetained reset objects for reuse. Unlike
+// sync.Pool it never retains more than its limit, objects are not dropped
+// by the garbage collector, and it reports hit and miss counts.
+type ObjectPool[T Resetter] struct {
+	mu          sync.Mutex
+	newFn       func() T
+	idle        []T
+	maxRetained int
+	hits        int64
+	misses      int64
+	dropped     int64
+}
+
+func NewObjectPool[T Resetter](newFn func() T, maxRetained int) *ObjectPool[T] {
+	return &ObjectPool[T]{
+		newFn:       newFn,
+		maxRetained: maxRetained,
+	}
+}
+
+// Get returns a retained object, or a new one if none is left.
+func (p *ObjectPool[T]) Get() T {
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		v := p.idle[n-1]
+		var zero T
+		p.idle[n-1] = zero
+		p.idle = p.idle[:n-1]
+		p.hits++
+		p.mu.Unlock()
+		return v
+	}
+	p.misses++
+	p.mu.Unlock()
+	return p.newFn()
+}
+
+// Put resets v and keeps it unless the pool already holds maxRetained
+// objects.
+func (p *ObjectPool[T]) Put(v T) {
+	v.Reset()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.idle) >= p.maxRetained {
+		p.dropped++
+		return
+	}
+	p.idle = append(p.idle, v)
+}
+
+func (p *ObjectPool[T]) Stats() ObjectStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ObjectStats{
+		Hits:     p.hits,
+		Misses:   p.misses,
+		Dropped:  p.dropped,
+		Retained: len(p.idle),
+	}
+}
+
+// SizedPool buckets objects into size classes, so a Get for a small
+// object doesn't take a large one and vice versa. Objects bigger than the
+// largest class are never retained.
+type SizedPool[T Resetter] struct {
+	classes  []int
+	buckets  []*ObjectPool[T]
+	newSized func(size int) T
+	size     func(T) int
+
+	mu      sync.Mutex
+	misses  int64 // Gets above the largest class
+	dropped int64 // Puts outside the class range
+}
+
+// NewSizedPool creates a pool with one bucket per size class, each keeping
+// up to maxPerClass objects. newSized allocates an object able to hold
+// size, and size reports how much an object can hold, e.g. its capacity.
+func NewSizedPool[T Resetter](classes []int, maxPerClass int, newSized func(size int) T, size func(T) int) *SizedPool[T] {
+	classes = slices.Clone(classes)
+	slices.Sort(classes)
+	classes = slices.Compact(classes)
+	p := SizedPool[T]{
+		classes:  classes,
+		buckets:  make([]*ObjectPool[T], len(classes)),
+		newSized: newSized,
+		size:     size,
+	}
+	for i, class := range classes {
+		p.buckets[i] = NewObjectPool(func() T { return newSized(class) }, maxPerClass)
+	}
+	return &p
+}
+
+// Get returns an object that can hold at least size. Requests above the
+// largest class are allocated directly and counted as misses.
+func (p *SizedPool[T]) Get(size int) T {
+	i, _ := slices.BinarySearch(p.classes, size)
+	if i == len(p.classes) {
+		p.mu.Lock()
+		p.misses++
+		p.mu.Unlock()
+		return p.newSized(size)
+	}
+	return p.buckets[i].Get()
+}
+
+// Put resets v and files it under the largest class it can still serve.
+// Objects larger than the largest class, or smaller than the smallest,
+// are dropped.
+func (p *SizedPool[T]) Put(v T) {
+	n := p.size(v)
+	i, found := slices.BinarySearch(p.classes, n)
+	if !found {
+		i--
+	}
+	if i < 0 || i == len(p.classes)-1 && n > p.classes[i] {
+		p.mu.Lock()
+		p.dropped++
+		p.mu.Unlock()
+		return
+	}
+	p.buckets[i].Put(v)
+}
+
+// Stats sums the stats of every size class, plus Gets and Puts that fell
+// outside them.
+func (p *SizedPool[T]) Stats() ObjectStats {
+	p.mu.Lock()
+	total := ObjectStats{Misses: p.misses, Dropped: p.dropped}
+	p.mu.Unlock()
+	for _, b := range p.buckets {
+		s := b.Stats()
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Dropped += s.Dropped
+		total.Retained += s.Retained
+	}
+	return total
+}
+
+// DefaultBufferClasses are the capacities NewBufferPool buckets by.
+var DefaultBufferClasses = []int{512, 4 << 10, 32 << 10, 256 << 10}
+
+// BufferPool is a SizedPool of byte buffers bucketed by capacity.
+type BufferPool = SizedPool[*bytes.Buffer]
+
+// NewBufferPool creates a pool of byte buffers with the given capacity
+// classes, or DefaultBufferClasses if none are given.
+func NewBufferPool(maxPerClass int, classes ...int) *BufferPool {
+	if len(classes) == 0 {
+		classes = DefaultBufferClasses
+	}
+	return NewSizedPool(classes, maxPerClass,
+		func(size int) *bytes.Buffer { return bytes.NewBuffer(make([]byte, 0, size)) },
+		(*bytes.Buffer).Cap)
+}
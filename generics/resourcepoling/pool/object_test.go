@@ -0,0 +1,96 @@
+package pool
+
+import (
+	"bytes"
+	"testing"
+)
+
+type encoder struct {
+	buf   []byte
+	reset int
+}
+
+func (e *encoder) Reset() {
+	e.buf = e.buf[:0]
+	e.reset++
+}
+
+func TestObjectPool(t *testing.T) {
+	p := NewObjectPool(func() *encoder { return &encoder{} }, 2)
+
+	a, b, c := p.Get(), p.Get(), p.Get()
+	a.buf = append(a.buf, "data"...)
+	p.Put(a)
+	p.Put(b)
+	p.Put(c)
+	if a.reset != 1 || len(a.buf) != 0 {
+		t.Fatal("expected Put to reset the object")
+	}
+
+	got := p.Get()
+	if got != b && got != a {
+		t.Fatal("expected Get to reuse a retained object")
+	}
+	want := ObjectStats{Hits: 1, Misses: 3, Dropped: 1, Retained: 1}
+	if s := p.Stats(); s != want {
+		t.Fatalf("expected %+v, got %+v", want, s)
+	}
+}
+
+func TestBufferPool(t *testing.T) {
+	p := NewBufferPool(4, 64, 1024)
+
+	small := p.Get(10)
+	if small.Cap() < 10 || small.Cap() >= 1024 {
+		t.Fatalf("expected a buffer from the 64 class, got capacity %d", small.Cap())
+	}
+	small.WriteString("hello")
+	p.Put(small)
+	if small.Len() != 0 {
+		t.Fatal("expected Put to reset the buffer")
+	}
+
+	if b := p.Get(100); b == small {
+		t.Fatal("expected a request above 64 not to get a 64 buffer")
+	} else {
+		if b.Cap() < 100 {
+			t.Fatalf("expected capacity of at least 100, got %d", b.Cap())
+		}
+		p.Put(b)
+	}
+	if b := p.Get(64); b != small {
+		t.Fatal("expected the 64 buffer to be reused")
+	}
+
+	huge := p.Get(4096)
+	if huge.Cap() < 4096 {
+		t.Fatalf("expected capacity of at least 4096, got %d", huge.Cap())
+	}
+	p.Put(huge)
+	p.Put(bytes.NewBuffer(make([]byte, 0, 8)))
+
+	want := ObjectStats{Hits: 1, Misses: 3, Dropped: 2, Retained: 1}
+	if s := p.Stats(); s != want {
+		t.Fatalf("expected %+v, got %+v", want, s)
+	}
+}
+
+func TestSizedPoolFilesGrownObjects(t *testing.T) {
+	p := NewBufferPool(4, 64, 1024)
+	b := p.Get(64)
+	b.Write(make([]byte, 2000))
+	p.Put(b)
+	if s := p.Stats(); s.Dropped != 1 {
+		t.Fatalf("expected a buffer grown past the largest class to be dropped, got %+v", s)
+	}
+
+	b = p.Get(64)
+	b.Grow(500)
+	p.Put(b)
+	if got := p.Get(1000); got == b {
+		t.Fatal("expected a buffer smaller than 1024 to stay in the 64 class")
+	}
+	if got := p.Get(64); got != b {
+		t.Fatal("expected the grown buffer to serve the 64 class")
+	}
+}
@@ -1,18 +1,20 @@
+// Package pool is a generic resource pool. It keeps between a minimum
+// idle level and a maximum number of open resources, creates them with a
+// factory func, expires them by age, idle time or use count, and checks
+// their health on borrow and in the background.
 package pool
 
-// type resource func(int) int
-
-// func display(int) int {
-// 	return 5
-// }
-// func main() {
-// 	var x resource = display
-// 	x(5)
-// }
 import (
 	"context"
 	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"log"
+	"os"
+	"reflect"
 	"sync"
+	"time"
 )
 
 var (
@@ -21,31 +23,125 @@ var (
 
 	// ErrInvalidConfig is returned when the pool is configured with invalid parameters.
 	ErrInvalidConfig = errors.New("invalid pool configuration")
+
+	// ErrAcquireTimeout is returned by Get when the pool's acquire timeout
+	// passes before a resource is available.
+	ErrAcquireTimeout = fmt.Errorf("timed out waiting for a resource: %w", context.DeadlineExceeded)
 )
 
-type resource interface {
+// Resource is implemented by pooled values. ID is the type the pool keys
+// its records by, such as an int counter, a UUID or a host:port pair. It
+// must be unique among the pool's open resources.
+type Resource[ID comparable] interface {
 	Close() error
+	GetID() ID
 }
 
-type Factory[T resource] func() (T, error)
+// Factory creates a resource. ctx belongs to the Get the resource is
+// created for, so a dial gives up when that Get does.
+type Factory[T any] func(ctx context.Context) (T, error)
 
-type Pool[T resource] struct {
+type Pool[T Resource[ID], ID comparable] struct {
 	mu        sync.Mutex
 	resources chan T
 	factory   Factory[T]
 	closed    bool
+	check     func(context.Context, T) error
+	health    healthCheck
+	logger    *log.Logger
+
+	targetIdle int
+	minIdle    int
+	onError    func(error)
+	refill     chan struct{}
+	done       chan struct{}
+
+	max          int
+	open         int // idle, in use and being created
+	inUse        int
+	freed        chan struct{}
+	waiting      int
+	waitCount    int64
+	waitDuration time.Duration
+
+	creating     int
+	maxCreating  int
+	createLimit  *tokenBucket
+	backoff      createBackoff
+	matchFactory func(ctx context.Context, match func(T) bool) (T, error)
+	weights      *weightSem
+
+	entries        map[ID]*entry
+	acquireTimeout time.Duration
+	maxLifetime    time.Duration
+	maxIdleTime    time.Duration
+	maxUses        int
+	reapInterval   time.Duration
+	reapReset      chan struct{} // wakes the reaper to pick up a new tick
+	reaping        bool          // the reaper holds idle resources off the channel
+	expiryJitter   time.Duration
+	jitterSeed     maphash.Seed
+	recycle        recycleLimiter
+	policy         EvictionPolicy[T]
+	keepalive      keepalive
+	onEvict        func(id ID, reason EvictReason)
+	evictions      map[EvictReason]int64
+	slowBorrow     time.Duration
+}
+
+// entry is the pool's bookkeeping for one open resource.
+type entry struct {
+	createdAt time.Time
+	lastUsed  time.Time
+	lastPing  time.Time
+	uses      int
+	fresh     bool // created for a waiting Get and not lent out yet
+
+	borrowedAt    time.Time // zero unless lent out
+	borrower      string
+	borrowedTime  time.Duration
+	longestBorrow time.Duration
+	weight        int        // weight held by the current borrow
+	weights       *weightSem // semaphore the weight was taken from
+}
+
+// Stats describes the pool's resources at one point in time. It is
+// modeled on database/sql's DBStats.
+type Stats struct {
+	MaxOpenConnections int
+	OpenConnections    int // idle, in use and being created
+	InUse              int
+	Idle               int
+	WaitCount          int64         // Gets that had to wait for a resource
+	WaitDuration       time.Duration // total time those Gets waited
+	MaxIdleClosed      int64
+	MaxLifetimeClosed  int64
+	MaxUsesClosed      int64
+	HealthCheckFailed  int64 // resources closed by a health check or keepalive ping
+	PolicyClosed       int64 // resources closed by an EvictionPolicy
 }
 
-func New[T resource](factory Factory[T], intial, max int) (*Pool[T], error) {
-	if intial < 0 || max <= 0 || intial > max {
+func New[T Resource[ID], ID comparable](factory Factory[T], initial, max int) (*Pool[T, ID], error) {
+	if initial < 0 || max <= 0 || initial > max {
 		return nil, ErrInvalidConfig
 	}
-	p := Pool[T]{
-		resources: make(chan T, max),
-		factory:   factory,
+	p := Pool[T, ID]{
+		resources:  make(chan T, max),
+		factory:    factory,
+		logger:     log.New(os.Stdout, "", 0),
+		targetIdle: initial,
+		refill:     make(chan struct{}, 1),
+		reapReset:  make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		max:        max,
+		open:       initial,
+		freed:      make(chan struct{}),
+		entries:    make(map[ID]*entry),
+		jitterSeed: maphash.MakeSeed(),
+		evictions:  make(map[EvictReason]int64),
 	}
-	for i := 0; i < intial; i++ {
-		res, err := factory()
+	for i := 0; i < initial; i++ {
+		res, err := factory(context.Background())
 		if err != nil {
 			close(p.resources)
 			for r := range p.resources {
@@ -53,82 +149,421 @@ func New[T resource](factory Factory[T], intial, max int) (*Pool[T], error) {
 			}
 			return nil, err
 		}
+		p.track(res)
 		p.resources <- res
 	}
+	go p.refiller()
 	return &p, nil
 }
 
-func (p *Pool[T]) Get(ctx context.Context) (T, error) {
-	select {
-	case res, ok := <-p.resources:
-		if !ok {
-			var zero T
-			return zero, ErrPoolClosed
+// SetBorrowCheck makes Get run check on every resource before handing it
+// out. Resources that fail are closed and Get waits for another one.
+// Resources created for the Get itself are not checked.
+func (p *Pool[T, ID]) SetBorrowCheck(check func(T) error) {
+	p.SetHealthCheck(func(_ context.Context, res T) error {
+		return check(res)
+	})
+}
+
+// SetAcquireTimeout bounds how long Get waits for a resource, on top of
+// any deadline on its context. Zero means Get waits as long as ctx allows.
+func (p *Pool[T, ID]) SetAcquireTimeout(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.acquireTimeout = d
+}
+
+// SetLogOutput sends the pool's log lines to w instead of stdout.
+func (p *Pool[T, ID]) SetLogOutput(w io.Writer) {
+	p.logger.SetOutput(w)
+}
+
+func (p *Pool[T, ID]) acquireContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	p.mu.Lock()
+	timeout := p.acquireTimeout
+	p.mu.Unlock()
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, timeout, ErrAcquireTimeout)
+}
+
+// Get returns an idle resource, or waits for one to be put back or
+// created. Creations run in the background, at most one per waiting Get,
+// and the new resource goes to whichever waiter is first, so a burst of
+// misses shares them. A failed creation is only reported to the Get that
+// started it.
+func (p *Pool[T, ID]) Get(ctx context.Context) (T, error) {
+	ctx, cancel := p.acquireContext(ctx)
+	defer cancel()
+	res, err := p.get(ctx)
+	if err != nil {
+		return res, err
+	}
+	if res, err = p.acquireWeight(ctx, res); err != nil {
+		return res, err
+	}
+	p.borrow(ctx, res)
+	return res, nil
+}
+
+func (p *Pool[T, ID]) get(ctx context.Context) (T, error) {
+	var zero T
+	var waitStart time.Time
+	defer func() {
+		if !waitStart.IsZero() {
+			p.mu.Lock()
+			p.waitDuration += time.Since(waitStart)
+			p.mu.Unlock()
 		}
+	}()
+	for {
+		select {
+		case res, ok := <-p.resources:
+			if !ok {
+				return zero, ErrPoolClosed
+			}
+			if ok, err := p.take(ctx, res); ok || err != nil {
+				return res, err
+			}
+			continue
+		default:
+		}
+
 		p.mu.Lock()
-		defer p.mu.Unlock()
 		if p.closed {
-			res.Close()
-			var zero T
+			p.mu.Unlock()
 			return zero, ErrPoolClosed
 		}
-		if len(p.resources) < cap(p.resources) {
-			go func() {
-				res, err := p.factory()
-				if err != nil {
-					// Log the error, can't do much more here.
-					return
-				}
-				// We need to check if the pool was closed while we were creating the resource.
-				p.mu.Lock()
-				defer p.mu.Unlock()
-				if p.closed {
-					res.Close()
-					return
+		p.waiting++
+		created, retry := p.startCreate(ctx)
+		if created == nil && waitStart.IsZero() {
+			waitStart = time.Now()
+			p.waitCount++
+		}
+		freed := p.freed
+		p.mu.Unlock()
+
+		var retryC <-chan time.Time
+		if retry > 0 {
+			retryC = time.After(retry)
+		}
+		select {
+		case res, ok := <-p.resources:
+			p.doneWaiting()
+			if !ok {
+				return zero, ErrPoolClosed
+			}
+			if ok, err := p.take(ctx, res); ok || err != nil {
+				return res, err
+			}
+		case err := <-created:
+			p.doneWaiting()
+			if err != nil {
+				if ctx.Err() != nil {
+					return zero, context.Cause(ctx)
 				}
-				p.resources <- res
-			}()
+				return zero, fmt.Errorf("cannot create resource: %w", err)
+			}
+		case <-freed:
+			p.doneWaiting()
+		case <-retryC:
+			p.doneWaiting()
+		case <-ctx.Done():
+			p.doneWaiting()
+			return zero, context.Cause(ctx)
 		}
-		return res, nil
-	case <-ctx.Done():
-		// The context was cancelled (e.g., timeout).
-		var zero T
-		return zero, ctx.Err()
 	}
 }
 
-func (p *Pool[T]) Len() int {
-	return len(p.resources)
+func (p *Pool[T, ID]) doneWaiting() {
+	p.mu.Lock()
+	p.waiting--
+	p.mu.Unlock()
 }
 
-func (p *Pool[T]) Put(res T) {
-	if any(res) == nil {
-		return
+// take lends out res, taken off the idle channel. It returns false with a
+// nil error if res had expired or failed the borrow check and was closed.
+// If ctx ends during the check, res goes back to the pool.
+func (p *Pool[T, ID]) take(ctx context.Context, res T) (bool, error) {
+	check, ok, err := p.borrowed(res)
+	if !ok {
+		return false, err
+	}
+	if check == nil {
+		return true, nil
+	}
+	err = p.runCheck(ctx, check, res)
+	if err == nil {
+		return true, nil
 	}
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	p.inUse--
+	if e := p.entries[res.GetID()]; e != nil {
+		e.borrowedAt = time.Time{}
+	}
+	p.mu.Unlock()
+	if ctx.Err() != nil {
+		p.putIdle(res)
+		return false, context.Cause(ctx)
+	}
+	p.evict(res, EvictCheckFailed)
+	return false, nil
+}
+
+// borrowed is called by Get after taking a resource off the channel. It
+// closes res if the pool is closed or res has expired, otherwise it counts
+// res as in use and returns the borrow check to run, if any. Either way it
+// wakes the refill worker.
+func (p *Pool[T, ID]) borrowed(res T) (func(context.Context, T) error, bool, error) {
+	p.mu.Lock()
 	if p.closed {
+		p.destroy(res)
+		p.mu.Unlock()
+		return nil, false, ErrPoolClosed
+	}
+	p.signalRefill()
+	now := time.Now()
+	e := p.entries[res.GetID()]
+	if reason, ok := p.checkExpiry(res.GetID(), e, now); ok {
+		p.mu.Unlock()
+		p.evict(res, reason)
+		return nil, false, nil
+	}
+	e.uses++
+	e.borrowedAt = now
+	p.inUse++
+	check := p.check
+	if e.fresh || !p.checkOnBorrow(e, now) {
+		check = nil
+	}
+	e.fresh = false
+	p.mu.Unlock()
+	return check, true, nil
+}
+
+// track starts the bookkeeping for a newly created resource. It must be
+// called with p.mu held.
+func (p *Pool[T, ID]) track(res T) *entry {
+	now := time.Now()
+	e := &entry{createdAt: now, lastUsed: now}
+	p.entries[res.GetID()] = e
+	return e
+}
+
+// destroy closes an open resource and gives up its slot. It must be called
+// with p.mu held.
+func (p *Pool[T, ID]) destroy(res T) {
+	delete(p.entries, res.GetID())
+	p.release()
+	res.Close()
+}
+
+// release gives up a slot counted in open and wakes Gets waiting for one.
+// It must be called with p.mu held.
+func (p *Pool[T, ID]) release() {
+	p.open--
+	p.wake()
+}
+
+// wake tells waiting Gets to check the pool again, because a slot was
+// freed or a creation finished. It must be called with p.mu held.
+func (p *Pool[T, ID]) wake() {
+	close(p.freed)
+	p.freed = make(chan struct{})
+}
+
+// Stats returns the pool's current accounting.
+func (p *Pool[T, ID]) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return Stats{
+		MaxOpenConnections: p.max,
+		OpenConnections:    p.open,
+		InUse:              p.inUse,
+		Idle:               len(p.resources),
+		WaitCount:          p.waitCount,
+		WaitDuration:       p.waitDuration,
+		MaxIdleClosed:      p.evictions[EvictIdleTimeout],
+		MaxLifetimeClosed:  p.evictions[EvictMaxLifetime],
+		MaxUsesClosed:      p.evictions[EvictMaxUses],
+		HealthCheckFailed:  p.evictions[EvictCheckFailed] + p.evictions[EvictPingFailed],
+		PolicyClosed:       p.evictions[EvictLRU] + p.evictions[EvictPolicy],
+	}
+}
+
+func (p *Pool[T, ID]) Len() int {
+	return len(p.resources)
+}
+
+// PutResult reports what Put did with a returned resource.
+type PutResult int
+
+const (
+	// Returned means the resource is idle in the pool again.
+	Returned PutResult = iota
+	// DiscardedFull means the pool already held max resources, after max
+	// was lowered, and the resource was closed.
+	DiscardedFull
+	// DestroyedClosed means the pool was closed and the resource was
+	// closed with it.
+	DestroyedClosed
+	// DestroyedNil means a nil resource was returned.
+	DestroyedNil
+	// DestroyedExpired means the resource was past its max lifetime or
+	// idle time and was closed.
+	DestroyedExpired
+	// DestroyedMaxUses means the resource had been borrowed max uses times
+	// and was closed.
+	DestroyedMaxUses
+	// DestroyedUnknown means the resource did not come from this pool and
+	// was closed.
+	DestroyedUnknown
+	// RejectedNotBorrowed means the resource belongs to the pool but is
+	// not lent out, such as one put back twice, and was left alone.
+	RejectedNotBorrowed
+)
+
+func (r PutResult) String() string {
+	switch r {
+	case Returned:
+		return "returned"
+	case DiscardedFull:
+		return "discarded, pool full"
+	case DestroyedClosed:
+		return "destroyed, pool closed"
+	case DestroyedNil:
+		return "destroyed, nil resource"
+	case DestroyedExpired:
+		return "destroyed, expired"
+	case DestroyedMaxUses:
+		return "destroyed, max uses"
+	case DestroyedUnknown:
+		return "destroyed, not from this pool"
+	case RejectedNotBorrowed:
+		return "rejected, not borrowed"
+	default:
+		return "unknown"
+	}
+}
+
+// Put returns res to the pool and reports what became of it. Resources
+// that have expired or reached the max uses are closed instead, as are
+// resources the pool did not create. Putting back a resource that is not
+// borrowed, such as a second Put, changes nothing.
+func (p *Pool[T, ID]) Put(res T) PutResult {
+	if isNil(res) {
+		return DestroyedNil
+	}
+	return p.put(res)
+}
+
+func (p *Pool[T, ID]) put(res T) PutResult {
+	p.mu.Lock()
+	e := p.entries[res.GetID()]
+	if e == nil {
+		p.mu.Unlock()
 		res.Close()
-		return
+		return DestroyedUnknown
+	}
+	if e.borrowedAt.IsZero() {
+		p.mu.Unlock()
+		return RejectedNotBorrowed
+	}
+	p.releaseWeight(e)
+	p.inUse--
+	p.giveBack(res.GetID(), e)
+	if p.closed {
+		p.destroy(res)
+		p.mu.Unlock()
+		return DestroyedClosed
+	}
+	now := time.Now()
+	if p.maxUses > 0 && e.uses >= p.maxUses {
+		p.mu.Unlock()
+		p.evict(res, EvictMaxUses)
+		return DestroyedMaxUses
+	}
+	e.lastUsed = now
+	if reason, ok := p.checkExpiry(res.GetID(), e, now); ok {
+		p.mu.Unlock()
+		p.evict(res, reason)
+		return DestroyedExpired
+	}
+	defer p.mu.Unlock()
+	if p.open > p.max {
+		p.destroy(res)
+		return DiscardedFull
 	}
 	select {
 	case p.resources <- res:
-
+		return Returned
 	default:
+		p.destroy(res)
+		return DiscardedFull
+	}
+}
+
+// Discard closes a borrowed resource instead of returning it, such as one
+// the caller found broken, and frees its slot. Resources that are not
+// borrowed are left alone.
+func (p *Pool[T, ID]) Discard(res T) {
+	if isNil(res) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.entries[res.GetID()]
+	if e == nil {
 		res.Close()
+		return
+	}
+	if e.borrowedAt.IsZero() {
+		return
 	}
+	p.releaseWeight(e)
+	p.inUse--
+	p.giveBack(res.GetID(), e)
+	p.destroy(res)
+	p.signalRefill()
 }
 
-func (p *Pool[T]) Close() {
+func (p *Pool[T, ID]) Close() {
 	p.mu.Lock()
 	if p.closed {
 		p.mu.Unlock()
 		return
 	}
 	p.closed = true
+	close(p.done)
 	close(p.resources)
+	if p.weights != nil {
+		p.weights.close()
+	}
+	p.wake()
 	p.mu.Unlock()
+	var idle []T
 	for res := range p.resources {
 		res.Close()
+		idle = append(idle, res)
+	}
+	p.mu.Lock()
+	for _, res := range idle {
+		delete(p.entries, res.GetID())
+	}
+	p.open -= len(idle)
+	p.mu.Unlock()
+}
+
+// isNil reports whether v is nil, including a nil pointer in a non-nil
+// interface, which would panic on GetID.
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+		return rv.IsNil()
 	}
+	return false
 }
@@ -0,0 +1,216 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countedResource tracks how many resources are alive at once.
+type countedResource struct {
+	id   int
+	live *atomic.Int32
+	once sync.Once
+}
+
+func (r *countedResource) GetID() int {
+	return r.id
+}
+
+func (r *countedResource) Close() error {
+	r.once.Do(func() { r.live.Add(-1) })
+	return nil
+}
+
+func TestOpenNeverExceedsMax(t *testing.T) {
+	var live, peak atomic.Int32
+	factory := func(ctx context.Context) (*countedResource, error) {
+		n := live.Add(1)
+		for {
+			m := peak.Load()
+			if n <= m || peak.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return &countedResource{id: int(testIDs.Add(1)), live: &live}, nil
+	}
+	p, err := New(factory, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			r, err := p.Get(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			time.Sleep(time.Millisecond)
+			p.Put(r)
+		}()
+	}
+	wg.Wait()
+
+	if n := peak.Load(); n > 4 {
+		t.Fatalf("expected at most 4 resources at once, saw %d", n)
+	}
+	s := p.Stats()
+	if s.InUse != 0 || s.OpenConnections != int(live.Load()) || s.OpenConnections > 4 {
+		t.Fatalf("unexpected stats after load: %+v, %d alive", s, live.Load())
+	}
+	if s.WaitCount == 0 {
+		t.Fatal("expected some Gets to wait")
+	}
+	p.Close()
+	if n := live.Load(); n != 0 {
+		t.Fatalf("expected Close to close every idle resource, %d alive", n)
+	}
+	if s := p.Stats(); s.OpenConnections != 0 {
+		t.Fatalf("expected no open resources after Close, got %+v", s)
+	}
+}
+
+func TestGetCreatesOnDemand(t *testing.T) {
+	p, err := New(newTestResource, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	a, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Stats(); s.OpenConnections != 2 || s.InUse != 2 || s.Idle != 0 {
+		t.Fatalf("expected 2 open and in use, got %+v", s)
+	}
+	if _, err := p.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Get at max to wait, got %v", err)
+	}
+
+	p.Put(a)
+	p.Put(b)
+	if s := p.Stats(); s.OpenConnections != 2 || s.InUse != 0 || s.Idle != 2 {
+		t.Fatalf("expected 2 idle, got %+v", s)
+	}
+}
+
+func TestStatsAfterWaits(t *testing.T) {
+	p, err := New(newTestResource, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	a, _ := p.Get(context.Background())
+	b, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan *testResource)
+	go func() {
+		r, err := p.Get(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		got <- r
+	}()
+	time.Sleep(30 * time.Millisecond)
+	p.Put(a)
+	c := <-got
+	p.Put(c)
+	p.Put(b)
+
+	s := p.Stats()
+	if s.WaitCount != 1 {
+		t.Fatalf("expected WaitCount 1, got %d", s.WaitCount)
+	}
+	if s.WaitDuration < 20*time.Millisecond {
+		t.Fatalf("expected WaitDuration of at least 20ms, got %s", s.WaitDuration)
+	}
+	if s.OpenConnections != 2 || s.Idle != 2 || s.InUse != 0 {
+		t.Fatalf("unexpected stats after Put: %+v", s)
+	}
+}
+
+func TestRefillReportsFactoryErrors(t *testing.T) {
+	var fail atomic.Bool
+	factory := func(ctx context.Context) (*testResource, error) {
+		if fail.Load() {
+			return nil, errors.New("backend down")
+		}
+		return newTestResource(ctx)
+	}
+	p, err := New(factory, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	errs := make(chan error, 10)
+	p.SetOnFactoryError(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+
+	fail.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	r, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Put(r)
+	select {
+	case err := <-errs:
+		if err.Error() != "backend down" {
+			t.Fatalf("unexpected error %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the factory error to be reported")
+	}
+
+	fail.Store(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for p.Len() < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if p.Len() != 1 {
+		t.Fatal("expected the worker to refill once the factory recovers")
+	}
+}
+
+func TestTargetIdle(t *testing.T) {
+	p, err := New(newTestResource, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetTargetIdle(3)
+
+	deadline := time.Now().Add(time.Second)
+	for p.Len() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if p.Len() != 3 {
+		t.Fatalf("expected 3 idle resources, got %d", p.Len())
+	}
+}
@@ -0,0 +1,27 @@
+package pool
+
+import (
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// probeDeadline reads with a deadline just ahead of now. The read fails
+// with a timeout on a healthy idle connection and returns EOF or data
+// otherwise. Any data read is lost, but such a connection is discarded
+// anyway.
+func probeDeadline(c net.Conn) error {
+	if err := c.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return err
+	}
+	var buf [1]byte
+	_, err := c.Read(buf[:])
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return c.SetReadDeadline(time.Time{})
+	}
+	if err != nil {
+		return err
+	}
+	return ErrConnClosedByPeer
+}
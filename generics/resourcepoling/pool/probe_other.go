@@ -0,0 +1,9 @@
+//go:build !unix
+
+package pool
+
+import "net"
+
+func probe(c net.Conn) error {
+	return probeDeadline(c)
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package pool
+
+import (
+	"net"
+	"syscall"
+)
+
+// probe peeks at the socket with MSG_DONTWAIT so it never consumes data or
+// waits. Connections that don't expose a file descriptor fall back to a
+// short read deadline.
+func probe(c net.Conn) error {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return probeDeadline(c)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var readErr error
+	err = raw.Read(func(fd uintptr) bool {
+		var buf [1]byte
+		_, _, readErr = syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		// Returning true stops RawConn.Read from waiting for readability.
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case readErr == syscall.EAGAIN || readErr == syscall.EWOULDBLOCK:
+		return nil
+	case readErr != nil:
+		return readErr
+	}
+	// n == 0 is EOF, n > 0 is data the peer sent unprompted.
+	return ErrConnClosedByPeer
+}
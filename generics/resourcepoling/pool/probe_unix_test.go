@@ -0,0 +1,34 @@
+//go:build unix
+
+package pool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProbePooledConnPeeks(t *testing.T) {
+	s := newEchoServer(t)
+	c, err := DialFactory("tcp", s.addr(), time.Second, 0)(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := ProbeConn(c); err != nil {
+		t.Fatalf("expected a healthy connection, got %s", err)
+	}
+
+	if _, err := c.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := ProbeConn(c); err == nil {
+		t.Fatal("expected pending data to fail the probe")
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	var buf [1]byte
+	if n, err := c.Read(buf[:]); err != nil || n != 1 || buf[0] != 'x' {
+		t.Fatalf("expected the probe to leave the pending data unread, got %q, %v", buf[:n], err)
+	}
+}
@@ -0,0 +1,130 @@
+package pool
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	refillMinBackoff = 50 * time.Millisecond
+	refillMaxBackoff = 5 * time.Second
+)
+
+// SetTargetIdle sets how many idle resources the refill worker keeps ready.
+// It defaults to the initial size passed to New and is capped at max.
+func (p *Pool[T, ID]) SetTargetIdle(n int) {
+	p.mu.Lock()
+	p.targetIdle = min(max(n, 0), cap(p.resources))
+	p.mu.Unlock()
+	p.signalRefill()
+}
+
+// SetOnFactoryError registers fn to be called with every error the refill
+// worker gets from the factory. It is called from the worker goroutine.
+func (p *Pool[T, ID]) SetOnFactoryError(fn func(error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onError = fn
+}
+
+// signalRefill wakes the refill worker without blocking. A pending signal
+// already covers this one.
+func (p *Pool[T, ID]) signalRefill() {
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
+
+// refiller is the pool's only background creator. Each time it is woken it
+// creates resources one at a time until the idle count reaches the target,
+// backing off between failed attempts.
+func (p *Pool[T, ID]) refiller() {
+	backoff := refillMinBackoff
+	for {
+		select {
+		case <-p.refill:
+		case <-p.done:
+			return
+		}
+		for p.reserveRefill() {
+			res, err := p.factory(context.Background())
+			if err != nil {
+				p.mu.Lock()
+				p.release()
+				onError := p.onError
+				p.mu.Unlock()
+				if onError != nil {
+					onError(err)
+				}
+				select {
+				case <-time.After(backoff):
+				case <-p.done:
+					return
+				}
+				backoff = min(backoff*2, refillMaxBackoff)
+				continue
+			}
+			backoff = refillMinBackoff
+			p.mu.Lock()
+			p.track(res)
+			p.mu.Unlock()
+			if !p.putIdle(res) {
+				break
+			}
+		}
+	}
+}
+
+// reserveRefill counts a resource about to be created by the worker, if
+// the pool is below its target or min idle level and has room under max.
+func (p *Pool[T, ID]) reserveRefill() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || p.reaping || len(p.resources) >= max(p.targetIdle, p.minIdle) || p.open >= p.max {
+		return false
+	}
+	p.open++
+	return true
+}
+
+// putIdle adds a tracked resource to the idle channel without counting a
+// use, closing it instead if the pool is closed or already full.
+func (p *Pool[T, ID]) putIdle(res T) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.destroy(res)
+		return false
+	}
+	select {
+	case p.resources <- res:
+		return true
+	default:
+		p.destroy(res)
+		return false
+	}
+}
+
+// replace creates a resource in place of one that failed a keepalive ping,
+// if there is room under max.
+func (p *Pool[T, ID]) replace() {
+	p.mu.Lock()
+	if p.closed || p.open >= p.max {
+		p.mu.Unlock()
+		return
+	}
+	p.open++
+	p.mu.Unlock()
+	res, err := p.factory(context.Background())
+	p.mu.Lock()
+	if err != nil {
+		p.release()
+		p.mu.Unlock()
+		p.logger.Printf("cannot replace resource: %s\n", err)
+		return
+	}
+	p.track(res)
+	p.mu.Unlock()
+	p.putIdle(res)
+}
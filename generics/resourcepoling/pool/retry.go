@@ -0,0 +1,147 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBadResource can be wrapped by Do callbacks to report that the
+// resource itself is broken, like driver.ErrBadConn in database/sql.
+var ErrBadResource = errors.New("resource is broken")
+
+// ErrorClass tells DoWithRetry what to do with a callback error.
+type ErrorClass int
+
+const (
+	// Terminal errors are returned to the caller straight away and the
+	// resource goes back to the pool.
+	Terminal ErrorClass = iota
+	// Retryable errors are retried on a fresh resource after a backoff.
+	// The failed resource is held until the retry has a different one,
+	// then goes back to the pool. When no other resource is idle and the
+	// pool is at max, it goes back straight away, since the retry could
+	// otherwise only wait for itself.
+	Retryable
+	// FatalToResource errors mean the resource is broken. It is discarded
+	// and the call is retried on a fresh resource after a backoff.
+	FatalToResource
+)
+
+// RetryPolicy configures DoWithRetry.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Classify sorts callback errors. When nil, errors wrapping
+	// ErrBadResource are FatalToResource and everything else is Terminal.
+	Classify func(error) ErrorClass
+}
+
+func (rp RetryPolicy) classify(err error) ErrorClass {
+	if rp.Classify != nil {
+		return rp.Classify(err)
+	}
+	if errors.Is(err, ErrBadResource) {
+		return FatalToResource
+	}
+	return Terminal
+}
+
+func (rp RetryPolicy) backoff(attempt int) time.Duration {
+	d := rp.InitialBackoff << attempt
+	if d < rp.InitialBackoff || (rp.MaxBackoff > 0 && d > rp.MaxBackoff) {
+		d = rp.MaxBackoff
+	}
+	return d
+}
+
+// Do runs fn on a pooled resource and returns it to the pool afterwards,
+// even if fn panics.
+func (p *Pool[T, ID]) Do(ctx context.Context, fn func(conn T) error) error {
+	conn, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	err = p.call(conn, fn)
+	p.Put(conn)
+	return err
+}
+
+// DoWithRetry is like Do, but classifies errors from fn with policy.
+// Broken resources are discarded rather than returned, and retryable
+// failures are retried on a fresh resource with exponential backoff, for
+// at most policy.MaxAttempts attempts or until ctx is done. If fn panics,
+// its resource is returned to the pool and the panic is re-raised.
+func (p *Pool[T, ID]) DoWithRetry(ctx context.Context, fn func(conn T) error, policy RetryPolicy) error {
+	attempts := policy.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	var err error
+	var failed T
+	holding := false
+	defer func() {
+		if holding {
+			p.Put(failed)
+		}
+	}()
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(policy.backoff(attempt - 1)):
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %s)", ctx.Err(), err)
+			}
+		}
+		var conn T
+		conn, err = p.Get(ctx)
+		if holding {
+			p.Put(failed)
+			holding = false
+		}
+		if err != nil {
+			return err
+		}
+		err = p.call(conn, fn)
+		if err == nil {
+			p.Put(conn)
+			return nil
+		}
+		switch policy.classify(err) {
+		case Terminal:
+			p.Put(conn)
+			return err
+		case FatalToResource:
+			p.logger.Printf("resource %v is broken, discarding it: %s\n", conn.GetID(), err)
+			p.Discard(conn)
+		default:
+			if p.spare() {
+				failed, holding = conn, true
+			} else {
+				p.Put(conn)
+			}
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+}
+
+// spare reports whether a Get could be served without the resources the
+// caller holds, because one is idle or there is room to create one.
+func (p *Pool[T, ID]) spare() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.resources) > 0 || p.open < p.max
+}
+
+// call runs fn on conn, returning conn to the pool if fn panics.
+func (p *Pool[T, ID]) call(conn T, fn func(conn T) error) error {
+	defer func() {
+		if r := recover(); r != nil {
+			p.Put(conn)
+			panic(r)
+		}
+	}()
+	return fn(conn)
+}
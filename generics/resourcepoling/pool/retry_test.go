@@ -0,0 +1,229 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDoWithRetry(t *testing.T) {
+	p := newDBPool(t, 1, 2)
+	p.SetTargetIdle(0)
+	ctx := context.Background()
+	errBusy := errors.New("server busy")
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Classify: func(err error) ErrorClass {
+			switch {
+			case errors.Is(err, errBusy):
+				return Retryable
+			case errors.Is(err, ErrBadResource):
+				return FatalToResource
+			}
+			return Terminal
+		},
+	}
+
+	var seen []int
+	err := p.DoWithRetry(ctx, func(c *dbConn) error {
+		seen = append(seen, c.GetID())
+		switch len(seen) {
+		case 1:
+			return fmt.Errorf("write failed: %w", ErrBadResource)
+		case 2:
+			return errBusy
+		}
+		return nil
+	}, policy)
+	if err != nil {
+		t.Fatalf("expected the third attempt to succeed, got %s", err)
+	}
+	if len(seen) != 3 || seen[1] == seen[0] {
+		t.Fatalf("expected broken resource %d to be replaced, used %v", seen[0], seen)
+	}
+	if seen[2] == seen[1] {
+		t.Fatalf("expected the retry after a retryable error to use a different resource, used %v", seen)
+	}
+
+	calls := 0
+	errSyntax := errors.New("syntax error")
+	err = p.DoWithRetry(ctx, func(c *dbConn) error {
+		calls++
+		return errSyntax
+	}, policy)
+	if !errors.Is(err, errSyntax) || calls != 1 {
+		t.Fatalf("expected a terminal error after 1 call, got %v after %d", err, calls)
+	}
+
+	err = p.DoWithRetry(ctx, func(c *dbConn) error {
+		return errBusy
+	}, policy)
+	if !errors.Is(err, errBusy) {
+		t.Fatalf("expected retries to give up with the last error, got %v", err)
+	}
+
+	idle := p.Len()
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the panic to be re-raised, got %v", r)
+			}
+		}()
+		p.DoWithRetry(ctx, func(c *dbConn) error {
+			panic("boom")
+		}, policy)
+	}()
+	if n := p.Len(); n != idle {
+		t.Fatalf("expected the resource to be returned after a panic, %d idle instead of %d", n, idle)
+	}
+}
+
+func TestDoWithRetryAtMax(t *testing.T) {
+	p := newDBPool(t, 1, 1)
+	errBusy := errors.New("server busy")
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Classify:       func(error) ErrorClass { return Retryable },
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	calls := 0
+	err := p.DoWithRetry(ctx, func(c *dbConn) error {
+		calls++
+		if calls < 3 {
+			return errBusy
+		}
+		return nil
+	}, policy)
+	if err != nil || calls != 3 {
+		t.Fatalf("expected the only resource to be retried, got %v after %d calls", err, calls)
+	}
+	if s := p.Stats(); s.InUse != 0 || s.Idle != 1 {
+		t.Fatalf("expected the resource back in the pool, got %+v", s)
+	}
+}
+
+func TestDoTx(t *testing.T) {
+	p := newDBPool(t, 1, 1)
+	p.SetTargetIdle(0)
+	ctx := context.Background()
+
+	var conn *dbConn
+	err := p.DoTx(ctx, func(c *dbConn) error {
+		conn = c
+		if !c.inTx {
+			t.Fatal("expected fn to run inside a transaction")
+		}
+		return nil
+	})
+	if err != nil || conn.inTx || p.Len() != 1 {
+		t.Fatalf("expected commit and return, got err %v, inTx %v, %d idle", err, conn.inTx, p.Len())
+	}
+
+	errInsert := errors.New("duplicate key")
+	err = p.DoTx(ctx, func(c *dbConn) error {
+		return errInsert
+	})
+	if !errors.Is(err, errInsert) || conn.inTx || p.Len() != 1 {
+		t.Fatalf("expected rollback and return, got err %v, inTx %v, %d idle", err, conn.inTx, p.Len())
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the panic to be re-raised, got %v", r)
+			}
+		}()
+		p.DoTx(ctx, func(c *dbConn) error {
+			panic("boom")
+		})
+	}()
+	if conn.inTx || p.Len() != 1 {
+		t.Fatalf("expected rollback after a panic, got inTx %v, %d idle", conn.inTx, p.Len())
+	}
+
+	err = p.DoTx(ctx, func(c *dbConn) error {
+		c.inTx = false
+		return errInsert
+	})
+	if !errors.Is(err, errInsert) || p.Len() != 0 || !conn.closed {
+		t.Fatalf("expected a failed rollback to discard the resource, got err %v, %d idle", err, p.Len())
+	}
+}
+
+func TestDoTxCommitFailure(t *testing.T) {
+	p := newDBPool(t, 1, 1)
+	p.SetTargetIdle(0)
+	ctx := context.Background()
+
+	var conn *dbConn
+	err := p.DoTx(ctx, func(c *dbConn) error {
+		conn = c
+		c.failCommit = true
+		return nil
+	})
+	if err == nil || conn.inTx || p.Len() != 1 {
+		t.Fatalf("expected a failed commit to be rolled back and returned, got err %v, inTx %v, %d idle", err, conn.inTx, p.Len())
+	}
+
+	err = p.DoTx(ctx, func(c *dbConn) error {
+		c.inTx = false
+		return nil
+	})
+	if err == nil || p.Len() != 0 {
+		t.Fatalf("expected a resource failing commit and rollback to be discarded, got err %v, %d idle", err, p.Len())
+	}
+}
+
+func TestPutResult(t *testing.T) {
+	p := newDBPool(t, 1, 2)
+	ctx := context.Background()
+	r1, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Put(r1); got != Returned {
+		t.Fatalf("expected %s, got %s", Returned, got)
+	}
+	err = p.Reconfigure(Config{Size: 1, MaxOpen: 1, MaxLifetime: time.Hour, IdleTimeout: time.Hour, ReaperInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Put(r2); got != DiscardedFull {
+		t.Fatalf("expected %s, got %s", DiscardedFull, got)
+	}
+	if s := p.Stats(); s.OpenConnections != 1 {
+		t.Fatalf("expected the discarded resource to free its slot, got %+v", s)
+	}
+	if got := p.Put(&dbConn{id: 99}); got != DestroyedUnknown {
+		t.Fatalf("expected %s, got %s", DestroyedUnknown, got)
+	}
+	if got := p.Put(nil); got != DestroyedNil {
+		t.Fatalf("expected %s, got %s", DestroyedNil, got)
+	}
+	if got := p.Put(r1); got != RejectedNotBorrowed {
+		t.Fatalf("expected a second Put to be %s, got %s", RejectedNotBorrowed, got)
+	}
+	p.Discard(r1)
+	if s := p.Stats(); s.OpenConnections != 1 || s.Idle != 1 || s.InUse != 0 {
+		t.Fatalf("expected a second Put and Discard to change nothing, got %+v", s)
+	}
+
+	r3, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	if got := p.Put(r3); got != DestroyedClosed {
+		t.Fatalf("expected %s, got %s", DestroyedClosed, got)
+	}
+}
@@ -0,0 +1,59 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+)
+
+// Transactional is implemented by resources that support transactions,
+// such as database connections.
+type Transactional interface {
+	Begin(ctx context.Context) error
+	Commit() error
+	Rollback() error
+}
+
+// DoTx runs fn inside a transaction on a pooled resource. The transaction
+// is committed if fn succeeds and rolled back if it returns an error or
+// panics, in which case the panic is re-raised after the rollback. A failed
+// commit is rolled back too, since the resource may still be inside the
+// transaction. A resource whose rollback fails is discarded instead of
+// returned.
+func (p *Pool[T, ID]) DoTx(ctx context.Context, fn func(conn T) error) (err error) {
+	conn, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	tx, ok := any(conn).(Transactional)
+	if !ok {
+		p.Put(conn)
+		return fmt.Errorf("resource %v does not support transactions", conn.GetID())
+	}
+	if err := tx.Begin(ctx); err != nil {
+		p.Put(conn)
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		r := recover()
+		if r == nil && err == nil {
+			if err = tx.Commit(); err == nil {
+				p.Put(conn)
+				return
+			}
+			err = fmt.Errorf("commit transaction: %w", err)
+		}
+		if rbErr := tx.Rollback(); rbErr != nil {
+			p.logger.Printf("rollback failed on resource %v, discarding it: %s\n", conn.GetID(), rbErr)
+			p.Discard(conn)
+			if r == nil {
+				err = fmt.Errorf("%w (rollback failed: %s)", err, rbErr)
+			}
+		} else {
+			p.Put(conn)
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
+	return fn(conn)
+}
@@ -0,0 +1,160 @@
+package pool
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Weighted is implemented by resources that cost more than one unit of
+// pool capacity, such as large buffers. Resources that don't implement it
+// weigh 1.
+type Weighted interface {
+	Weight() int
+}
+
+// weightSem is a weighted semaphore that grants waiters strictly in FIFO
+// order, so a heavy resource is not starved by a stream of light ones.
+type weightSem struct {
+	mu      sync.Mutex
+	size    int
+	cur     int
+	waiters list.List
+	done    chan struct{}
+}
+
+type weightWaiter struct {
+	n     int
+	ready chan struct{}
+}
+
+func newWeightSem(size int) *weightSem {
+	return &weightSem{size: size, done: make(chan struct{})}
+}
+
+func (s *weightSem) acquire(ctx context.Context, n int) error {
+	s.mu.Lock()
+	if n > s.size {
+		s.mu.Unlock()
+		return fmt.Errorf("resource weight %d exceeds pool capacity %d", n, s.size)
+	}
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	w := weightWaiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	var err error
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		err = context.Cause(ctx)
+	case <-s.done:
+		err = ErrPoolClosed
+	}
+	s.mu.Lock()
+	select {
+	case <-w.ready:
+		// Granted while giving up, hand the weight back.
+		s.cur -= n
+	default:
+		s.waiters.Remove(elem)
+	}
+	s.notify()
+	s.mu.Unlock()
+	return err
+}
+
+func (s *weightSem) release(n int) {
+	s.mu.Lock()
+	s.cur -= n
+	s.notify()
+	s.mu.Unlock()
+}
+
+// notify grants waiters from the front of the queue while they fit. It must
+// be called with s.mu held.
+func (s *weightSem) notify() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(weightWaiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
+
+func (s *weightSem) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+}
+
+// SetWeightCapacity limits the total Weight of borrowed resources to
+// capacity. Get blocks until enough weight has been returned. It should be
+// called before the pool is used.
+func (p *Pool[T, ID]) SetWeightCapacity(capacity int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if capacity <= 0 {
+		p.weights = nil
+		return
+	}
+	p.weights = newWeightSem(capacity)
+}
+
+func weightOf(res any) int {
+	if w, ok := res.(Weighted); ok && w.Weight() > 0 {
+		return w.Weight()
+	}
+	return 1
+}
+
+// acquireWeight waits for res's weight to be free, putting res back if
+// ctx ends first.
+func (p *Pool[T, ID]) acquireWeight(ctx context.Context, res T) (T, error) {
+	var zero T
+	p.mu.Lock()
+	weights := p.weights
+	p.mu.Unlock()
+	if weights == nil {
+		return res, nil
+	}
+	n := weightOf(res)
+	if err := weights.acquire(ctx, n); err != nil {
+		p.put(res)
+		return zero, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.entries[res.GetID()]; e != nil {
+		e.weight, e.weights = n, weights
+	} else {
+		weights.release(n)
+	}
+	return res, nil
+}
+
+// releaseWeight gives back the weight held by the borrow behind e, if any.
+// It must be called with p.mu held, once the borrow is known to end.
+func (p *Pool[T, ID]) releaseWeight(e *entry) {
+	if e.weights != nil {
+		e.weights.release(e.weight)
+	}
+	e.weight, e.weights = 0, nil
+}
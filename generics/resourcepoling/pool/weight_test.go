@@ -0,0 +1,103 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type buffer struct {
+	testResource
+	size int
+}
+
+func (b *buffer) Weight() int {
+	return b.size
+}
+
+func TestWeightCapacity(t *testing.T) {
+	var mu sync.Mutex
+	sizes := []int{2, 1, 3}
+	factory := func(ctx context.Context) (*buffer, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		r, _ := newTestResource(ctx)
+		b := &buffer{testResource: *r, size: sizes[0]}
+		sizes = sizes[1:]
+		return b, nil
+	}
+	p, err := New(factory, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetWeightCapacity(4)
+	ctx := context.Background()
+
+	small, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiny, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 of 4 units are borrowed, the 3-unit buffer has to wait.
+	got := make(chan *buffer)
+	go func() {
+		b, err := p.Get(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		got <- b
+	}()
+	select {
+	case b := <-got:
+		t.Fatalf("expected the large buffer to wait, got %d", b.GetID())
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	p.Put(small)
+	select {
+	case b := <-got:
+		if b.Weight() != 3 {
+			t.Fatalf("expected the 3-unit buffer, got %d", b.GetID())
+		}
+		p.Put(b)
+	case <-time.After(time.Second):
+		t.Fatal("expected the large buffer once its weight was returned")
+	}
+	p.Put(tiny)
+}
+
+func TestWeightNotFreedByStrayPut(t *testing.T) {
+	p := newTestPool(t, 1, 2)
+	p.SetWeightCapacity(1)
+	ctx := context.Background()
+
+	r, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(r)
+	p.Put(r)
+	p.Put(&testResource{id: -1})
+
+	held, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// held has the only unit, unless a stray Put above gave back extra.
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if r, err := p.Get(short); err == nil {
+		t.Fatalf("expected Get to wait for the held weight, got %d", r.GetID())
+	}
+	p.Put(held)
+	if r, err = p.Get(ctx); err != nil {
+		t.Fatal(err)
+	}
+	p.Put(r)
+}
@@ -1,3 +1,7 @@
 module pool
 
 go 1.24.0
+
+require github.com/Kama001/goadvanced/generics/resourcepoling v0.0.0
+
+replace github.com/Kama001/goadvanced/generics/resourcepoling => ../generics/resourcepoling
@@ -5,32 +5,13 @@ import (
 	"fmt"
 	"sync"
 	"time"
-)
-
-type Resource interface {
-	Close() error
-	GetID() string
-	IsNil() bool
-}
 
-type Factory[T Resource] interface {
-	Create() (T, error)
-	Destroy(T) error
-}
-
-type Pool[T Resource] struct {
-	mu           sync.Mutex
-	resources    chan T
-	closed       bool
-	factory      Factory[T]
-	min          int
-	max          int
-	currentCount int
-	timeout      time.Duration
-}
+	"github.com/Kama001/goadvanced/generics/resourcepoling/pool"
+)
 
 type DBConnection struct {
-	ID string
+	ID   string
+	Tags map[string]string
 }
 
 func (conn *DBConnection) Close() error {
@@ -42,165 +23,79 @@ func (conn *DBConnection) GetID() string {
 	return conn.ID
 }
 
-func (conn *DBConnection) IsNil() bool {
-	if conn == nil {
-		return true
-	} else {
-		return false
-	}
+func (conn *DBConnection) GetTags() map[string]string {
+	return conn.Tags
 }
 
 type DBFactory struct {
 	counter int
 	mu      sync.Mutex
+	TagSets []map[string]string
 }
 
-func (f *DBFactory) Create() (*DBConnection, error) {
+func (f *DBFactory) Create(ctx context.Context) (*DBConnection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	f.mu.Lock()
 	f.counter++
+	id := f.counter
 	f.mu.Unlock()
-	fmt.Printf("Creating DB connection %d\n", f.counter)
+	fmt.Printf("Creating DB connection %d\n", id)
 	conn := &DBConnection{
-		ID: fmt.Sprintf("%d", f.counter),
+		ID: fmt.Sprintf("%d", id),
 	}
 	return conn, nil
 }
 
-func (f *DBFactory) Destroy(conn *DBConnection) error {
-	fmt.Println("Destroying db connection")
-	conn.Close()
-	return nil
-}
-
-func New[T Resource](min, max int, factory Factory[T], timeout time.Duration) (*Pool[T], error) {
-	p := &Pool[T]{
-		resources: make(chan T, max),
-		factory:   factory,
-		min:       min,
-		timeout:   timeout,
-	}
-	for i := 0; i < p.min; i++ {
-		res, err := p.factory.Create()
-		if err != nil {
-			fmt.Println("cannot add resource!!!")
-			close(p.resources)
-			return nil, fmt.Errorf("error creating pool")
+// CreateMatching creates a connection using the first of f.TagSets whose
+// candidate connection satisfies match.
+func (f *DBFactory) CreateMatching(ctx context.Context, match func(*DBConnection) bool) (*DBConnection, error) {
+	for _, tags := range f.TagSets {
+		if !match(&DBConnection{Tags: tags}) {
+			continue
 		}
-
-		p.resources <- res
-	}
-	return p, nil
-}
-
-func (p *Pool[T]) Get(ctx context.Context) (T, error) {
-	var zero T
-	select {
-	case res, ok := <-p.resources:
-		if !ok {
-			return zero, fmt.Errorf("Pool closed")
-		}
-		return res, nil
-	case <-ctx.Done():
-		return zero, ctx.Err()
-	default:
-		p.mu.Lock()
-		if p.closed {
-			p.mu.Unlock()
-			return zero, fmt.Errorf("Pool closed")
-		}
-		p.mu.Unlock()
-		if p.currentCount < p.max {
-			p.inc()
-			fmt.Println("Pool: No resource available, creating new one...")
-			res, err := p.factory.Create()
-			if err != nil {
-				p.dec()
-				return zero, fmt.Errorf("cannot create resource")
-			}
-			return res, nil
-		}
-		select {
-		case res, ok := <-p.resources:
-			if !ok {
-				return zero, fmt.Errorf("pool: pool is closed while waiting")
-			}
-			return res, nil
-		case <-ctx.Done():
-			return zero, ctx.Err()
-		case <-time.After(p.timeout):
-			return zero, fmt.Errorf("timed out waiting for resources")
+		conn, err := f.Create(ctx)
+		if err != nil {
+			return nil, err
 		}
+		conn.Tags = tags
+		return conn, nil
 	}
-}
-
-func (p *Pool[T]) inc() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.currentCount++
-
-}
-
-func (p *Pool[T]) dec() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.currentCount--
-}
-
-func (p *Pool[T]) Put(res T) {
-	if res.IsNil() {
-		fmt.Println("nil resource received!!!")
-		p.factory.Destroy(res)
-		p.dec()
-		return
-	}
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	if p.closed {
-		p.mu.Unlock()
-		p.factory.Destroy(res)
-		fmt.Printf("pool closed, destroying resource %s\n", res.GetID())
-		p.dec()
-		return
-	}
-	select {
-	case p.resources <- res:
-		// fmt.Printf("resource %s returned\n", res.GetID())
-	default:
-		fmt.Printf("pool full, destroying resource %s\n", res.GetID())
-		p.factory.Destroy(res)
-		p.dec()
-		return
-	}
-}
-
-func (p *Pool[T]) Close() {
-	p.mu.Lock()
-	p.closed = true
-	p.mu.Unlock()
-	close(p.resources)
-	for res := range p.resources {
-		fmt.Printf("destroying resource %s\n", res.GetID())
-		p.factory.Destroy(res)
-		p.dec()
-	}
+	return nil, pool.ErrNoMatch
 }
 
 func main() {
-	dbFactory := &DBFactory{}
-	dbPool, err := New[*DBConnection](2, 5, dbFactory, 3*time.Second)
+	dbFactory := &DBFactory{TagSets: []map[string]string{
+		{"role": "primary"},
+		{"role": "replica"},
+	}}
+	dbPool, err := pool.New(dbFactory.Create, 2, 5)
 	if err != nil {
 		fmt.Printf("error creating pool %s", err.Error())
+		return
 	}
+	dbPool.SetAcquireTimeout(3 * time.Second)
+	dbPool.SetMaxCreating(2)
+	dbPool.SetCreateRate(5, 2)
+	dbPool.SetMatchingFactory(dbFactory.CreateMatching)
 	defer dbPool.Close()
 	ctx := context.Background()
+	replica := pool.HasTags[*DBConnection](map[string]string{"role": "replica"})
 	for i := 0; i < 7; i++ { // Try to get more than max
 		go func(i int) {
-			conn, err := dbPool.Get(ctx)
+			get := dbPool.Get
+			if i%3 == 0 {
+				get = func(ctx context.Context) (*DBConnection, error) {
+					return dbPool.GetMatching(ctx, replica)
+				}
+			}
+			conn, err := get(ctx)
 			if err != nil {
 				fmt.Printf("Goroutine %d: Failed to get connection: %v\n", i, err)
 				return
 			}
-			fmt.Printf("Goroutine %d: Got connection %s\n", i, conn.GetID())
+			fmt.Printf("Goroutine %d: Got connection %s %v\n", i, conn.GetID(), conn.Tags)
 			time.Sleep(3 * time.Second) // Simulate work
 			dbPool.Put(conn)
 			fmt.Printf("Goroutine %d: Put connection %s back\n", i, conn.GetID())
@@ -2,59 +2,68 @@ package main
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/Kama001/goadvanced/generics/resourcepoling/pool"
 )
 
 func TestPool_BasicUsage(t *testing.T) {
 	factory := &DBFactory{}
-	pool, err := New[*DBConnection](2, 5, factory, 3*time.Second)
+	dbPool, err := pool.New(factory.Create, 2, 5)
 	if err != nil {
 		t.Fatalf("Failed to create pool: %v", err)
 	}
+	dbPool.SetAcquireTimeout(3 * time.Second)
 
 	ctx := context.Background()
 
 	// Get two resources (initially created)
-	res1, err := pool.Get(ctx)
+	res1, err := dbPool.Get(ctx)
 	if err != nil {
 		t.Fatalf("Failed to get resource 1: %v", err)
 	}
 	t.Logf("Got resource: %s", res1.GetID())
 
-	res2, err := pool.Get(ctx)
+	res2, err := dbPool.Get(ctx)
 	if err != nil {
 		t.Fatalf("Failed to get resource 2: %v", err)
 	}
 	t.Logf("Got resource: %s", res2.GetID())
 
 	// Put back one resource
-	pool.Put(res1)
+	dbPool.Put(res1)
 	t.Logf("Put back resource: %s", res1.GetID())
 
 	// Get again (should reuse)
-	res3, err := pool.Get(ctx)
+	res3, err := dbPool.Get(ctx)
 	if err != nil {
 		t.Fatalf("Failed to get resource 3: %v", err)
 	}
 	t.Logf("Got resource: %s", res3.GetID())
 
 	// Create new ones until limit
+	var wg sync.WaitGroup
 	for i := 0; i < 3; i++ {
+		wg.Add(1)
 		go func(i int) {
-			res, err := pool.Get(ctx)
+			defer wg.Done()
+			res, err := dbPool.Get(ctx)
 			if err != nil {
-				t.Fatalf("Failed to get resource %d: %v", i+4, err)
+				t.Errorf("Failed to get resource %d: %v", i+4, err)
+				return
 			}
 			t.Logf("Got new resource: %s", res.GetID())
 		}(i)
 	}
+	wg.Wait()
 
 	// This call should block or fail due to reaching max, so use timeout
 	ctxTimeout, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
-	_, err = pool.Get(ctxTimeout)
+	_, err = dbPool.Get(ctxTimeout)
 	if err == nil {
 		t.Error("Expected error or timeout due to max pool size")
 	} else {
@@ -62,7 +71,7 @@ func TestPool_BasicUsage(t *testing.T) {
 	}
 
 	// Clean up
-	pool.Put(res2)
-	pool.Put(res3)
-	pool.Close()
+	dbPool.Put(res2)
+	dbPool.Put(res3)
+	dbPool.Close()
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Kama001/goadvanced/generics/resourcepoling/pool"
+)
+
+func TestUseCountSurvivesPut(t *testing.T) {
+	p, err := pool.New(newFactory(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetMaxUses(2)
+	for i := 0; i < 2; i++ {
+		res, err := p.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ID != 1 {
+			t.Fatalf("borrow %d: expected resource 1, got %d", i, res.ID)
+		}
+		p.Put(res)
+	}
+	res, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ID != 2 {
+		t.Fatalf("expected a new resource after max uses, got %d", res.ID)
+	}
+	if s := p.Stats(); s.MaxUsesClosed != 1 {
+		t.Fatalf("expected MaxUsesClosed 1, got %d", s.MaxUsesClosed)
+	}
+	p.Put(res)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("RESPOOL_SIZE", "1")
+	t.Setenv("RESPOOL_MAX_OPEN", "1")
+	t.Setenv("RESPOOL_MAX_USES", "1")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxLifetime != defaults.MaxLifetime {
+		t.Fatalf("expected unset fields to keep their defaults, got %+v", cfg)
+	}
+	p, err := pool.NewFromConfig(newFactory(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	res, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(res)
+	if s := p.Stats(); s.MaxOpenConnections != 1 || s.MaxUsesClosed != 1 {
+		t.Fatalf("expected the env settings to be applied, got %+v", s)
+	}
+
+	t.Setenv("RESPOOL_MAX_USES", "-1")
+	if _, err := loadConfig(); err == nil {
+		t.Fatal("expected a negative max uses to be rejected")
+	}
+}